@@ -1,17 +1,32 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"flag"
 	"fmt"
-	"log"
+	"net/http"
 	"os"
+	"os/signal"
 	"sort"
 	"strings"
+	"sync"
+	"syscall"
+	"text/template"
+	"time"
 	"vcenterhoundgo/internal/bloodhound"
 	"vcenterhoundgo/internal/collector"
 	"vcenterhoundgo/internal/config"
+	"vcenterhoundgo/internal/credentials"
+	"vcenterhoundgo/internal/directory"
 	"vcenterhoundgo/internal/graph"
+	"vcenterhoundgo/internal/logging"
 	"vcenterhoundgo/internal/output"
+	"vcenterhoundgo/internal/scheduler"
+	"vcenterhoundgo/internal/stats"
+
+	"github.com/hashicorp/go-hclog"
+	"golang.org/x/sync/errgroup"
 )
 
 func main() {
@@ -19,133 +34,511 @@ func main() {
 	server := flag.String("s", "", "vCenter server(s), comma-separated")
 	user := flag.String("u", "", "Username")
 	password := flag.String("p", "", "Password")
+	credsSpec := flag.String("credentials", "", "Credentials provider URI, e.g. \"env://\", \"file:///etc/vcenterhoundgo/secrets.yaml\", \"vault://secret/data/vcenter/{host}\" or \"keyring://vcenterhoundgo\". Supersedes -u/-p when set.")
 	port := flag.Int("P", 443, "Port")
 	outPath := flag.String("o", "vcenter_graph.json", "Output file")
 
-	debug := flag.Bool("debug", false, "Enable debug logging and extended summary")
+	debug := flag.Bool("debug", false, "Shorthand for -log-level=debug, plus an extended summary at the end of the run")
+	logLevel := flag.String("log-level", "info", "Log level: trace, debug, info, warn, error")
+	logJSON := flag.Bool("log-json", false, "Emit logs as JSON instead of human-readable text")
 
 	// BloodHound Integration
 	bhURL := flag.String("bh-url", "", "BloodHound URL (e.g. https://bloodhound.example.com)")
 	bhKeyID := flag.String("bh-key-id", "", "BloodHound Key ID")
 	bhKeySecret := flag.String("bh-key-secret", "", "BloodHound Key Secret")
+	bhUpload := flag.Bool("bh-upload", false, "Upload the collected graph directly to BloodHound instead of only writing it to disk")
+	workers := flag.Int("workers", 4, "Maximum number of vCenter hosts to collect from concurrently")
+
+	ldapUser := flag.String("ldap-user", "", "LDAP bind DN/username for NetBIOS resolution (anonymous bind if empty)")
+	ldapPass := flag.String("ldap-pass", "", "LDAP bind password for NetBIOS resolution")
+	ldapCache := flag.String("ldap-cache", "netbios_cache.json", "Path to the on-disk NetBIOS resolution cache")
+
+	// Daemon mode: run on a schedule instead of once and exit.
+	cronExpr := flag.String("cron", "", "Cron expression (e.g. \"0 */6 * * *\") for recurring collection; runs in daemon mode if set")
+	interval := flag.Duration("interval", 0, "Fixed interval (e.g. 6h) for recurring collection, used if -cron is empty; runs in daemon mode if set")
+	outputPattern := flag.String("output-pattern", "", "Go template for each daemon run's output filename, e.g. \"vcenter_{{.Timestamp}}.json\". Supports {{.Timestamp}} and {{.Host}}. Defaults to -o.")
+	healthListen := flag.String("health-listen", "", "Address to serve /healthz and /metrics on in daemon mode (e.g. \":9090\"); disabled if empty")
+
+	// Phase-level timing and progress telemetry.
+	timeFlag := flag.Bool("time", false, "Dump a per-host, per-phase timing table to stderr when collection finishes")
+	statsOut := flag.String("stats-out", "", "Write a JSON per-host, per-phase timing/volume report to this file")
+	metricsListen := flag.String("metrics-listen", "", "Address to serve Prometheus-format collection metrics on (e.g. \":9091\"); disabled if empty")
+
+	// Inventory-path include/exclude filters, comma-separated glob patterns
+	// like "/DC1/vm/prod/**" or "/DC1/host/cluster-*/**".
+	vmInclude := flag.String("vm-include", "", "Comma-separated inventory-path globs; only matching VMs are collected")
+	vmExclude := flag.String("vm-exclude", "", "Comma-separated inventory-path globs; matching VMs are skipped")
+	hostInclude := flag.String("host-include", "", "Comma-separated inventory-path globs; only matching ESXi hosts are collected")
+	hostExclude := flag.String("host-exclude", "", "Comma-separated inventory-path globs; matching ESXi hosts are skipped")
+	clusterInclude := flag.String("cluster-include", "", "Comma-separated inventory-path globs; only matching clusters are collected")
+	clusterExclude := flag.String("cluster-exclude", "", "Comma-separated inventory-path globs; matching clusters are skipped")
+	datastoreInclude := flag.String("datastore-include", "", "Comma-separated inventory-path globs; only matching datastores are collected")
+	datastoreExclude := flag.String("datastore-exclude", "", "Comma-separated inventory-path globs; matching datastores are skipped")
+
+	disableRESTTagging := flag.Bool("no-rest-tagging", false, "Skip collecting tags/categories via the vAPI REST endpoint, for environments where it's blocked")
+
+	collectGuest := flag.Bool("collect-guest", false, "Collect in-guest processes and, on Windows, local accounts via GuestOperationsManager. Requires -guest-credentials.")
+	guestCredentialsPath := flag.String("guest-credentials", "", "Path to a YAML file mapping VM name globs/guest OS families to guest credentials, required by -collect-guest")
 
 	flag.Parse()
 
-	if *server == "" || *user == "" || *password == "" {
+	if *debug {
+		*logLevel = "debug"
+	}
+	root := logging.New("vcenterhoundgo", logging.Options{Level: *logLevel, JSON: *logJSON})
+
+	if *server == "" || (*credsSpec == "" && (*user == "" || *password == "")) {
 		fmt.Println("Usage: vCenterHound -s <host> -u <user> -p <pass>")
+		fmt.Println("   or: vCenterHound -s <host> -credentials <scheme>://...")
 		flag.PrintDefaults()
 		os.Exit(1)
 	}
 
-	// Resolve Domains if BH config provided
+	var credsProvider credentials.Provider
+	if *credsSpec != "" {
+		var err error
+		credsProvider, err = credentials.New(*credsSpec)
+		if err != nil {
+			root.Error("invalid -credentials", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	var guestCreds *credentials.GuestCredentialMap
+	if *collectGuest {
+		if *guestCredentialsPath == "" {
+			root.Error("-collect-guest requires -guest-credentials")
+			os.Exit(1)
+		}
+		var err error
+		guestCreds, err = credentials.LoadGuestCredentialMap(*guestCredentialsPath)
+		if err != nil {
+			root.Error("invalid -guest-credentials", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	hosts := strings.Split(*server, ",")
+
+	registry := stats.NewRegistry()
+	if *metricsListen != "" {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/metrics", registry.ServeMetrics)
+		metricsSrv := &http.Server{Addr: *metricsListen, Handler: mux}
+		go func() {
+			if err := metricsSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				root.Error("collection metrics server stopped unexpectedly", "error", err)
+			}
+		}()
+		root.Info("serving collection metrics", "addr", *metricsListen)
+	}
+
+	run := func(ctx context.Context, outputPath string, sched *scheduler.Scheduler) error {
+		return collectOnce(ctx, collectOnceParams{
+			hosts:       hosts,
+			user:        *user,
+			password:    *password,
+			credentials: credsProvider,
+			port:        *port,
+			outputPath:  outputPath,
+			debug:       *debug,
+			bhURL:       *bhURL,
+			bhKeyID:     *bhKeyID,
+			bhKeySecret: *bhKeySecret,
+			bhUpload:    *bhUpload,
+			workers:     *workers,
+			ldapUser:    *ldapUser,
+			ldapPass:    *ldapPass,
+			ldapCache:   *ldapCache,
+			logger:      root,
+			sched:       sched,
+			registry:    registry,
+			time:        *timeFlag,
+			statsOut:    *statsOut,
+
+			vmInclude:        splitList(*vmInclude),
+			vmExclude:        splitList(*vmExclude),
+			hostInclude:      splitList(*hostInclude),
+			hostExclude:      splitList(*hostExclude),
+			clusterInclude:   splitList(*clusterInclude),
+			clusterExclude:   splitList(*clusterExclude),
+			datastoreInclude: splitList(*datastoreInclude),
+			datastoreExclude: splitList(*datastoreExclude),
+
+			disableRESTTagging: *disableRESTTagging,
+
+			collectGuest:     *collectGuest,
+			guestCredentials: guestCreds,
+		})
+	}
+
+	daemonMode := *cronExpr != "" || *interval > 0
+	if !daemonMode {
+		if err := run(context.Background(), *outPath, nil); err != nil {
+			root.Error("collection failed", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	sched, err := scheduler.New(scheduler.Options{
+		Cron:        *cronExpr,
+		Interval:    *interval,
+		MetricsAddr: *healthListen,
+		Logger:      root.Named("scheduler"),
+	})
+	if err != nil {
+		root.Error("invalid schedule", "error", err)
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	// Every flag this binary takes is immutable for its lifetime, but the
+	// BloodHound domain map is already re-fetched on every scheduled run, so
+	// a SIGHUP's only job is to say so loudly rather than get swallowed as
+	// the default terminate-the-process behavior.
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	go func() {
+		for range reload {
+			root.Info("SIGHUP received, config will be re-resolved on the next scheduled run")
+		}
+	}()
+
+	root.Info("running in daemon mode", "cron", *cronExpr, "interval", interval.String())
+	go func() {
+		<-ctx.Done()
+		root.Info("shutdown signal received, disconnecting in-flight collectors")
+		sched.Shutdown()
+	}()
+
+	err = sched.Run(ctx, func(runCtx context.Context) error {
+		path, err := renderOutputPath(*outputPattern, *outPath, hosts)
+		if err != nil {
+			return err
+		}
+		return run(runCtx, path, sched)
+	})
+	if err != nil {
+		root.Error("scheduler exited with error", "error", err)
+		os.Exit(1)
+	}
+}
+
+// outputPathData is the template context for -output-pattern.
+type outputPathData struct {
+	Timestamp string
+	Host      string
+}
+
+// renderOutputPath expands pattern (if set) with the current timestamp and
+// the collected hosts, falling back to fallback when pattern is empty.
+func renderOutputPath(pattern, fallback string, hosts []string) (string, error) {
+	if pattern == "" {
+		return fallback, nil
+	}
+
+	tmpl, err := template.New("output-pattern").Parse(pattern)
+	if err != nil {
+		return "", fmt.Errorf("invalid -output-pattern: %w", err)
+	}
+
+	data := outputPathData{
+		Timestamp: time.Now().UTC().Format("20060102T150405Z"),
+		Host:      strings.Join(hosts, "+"),
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render -output-pattern: %w", err)
+	}
+	return buf.String(), nil
+}
+
+type collectOnceParams struct {
+	hosts       []string
+	user        string
+	password    string
+	credentials credentials.Provider
+	port        int
+	outputPath  string
+	debug       bool
+	bhURL       string
+	bhKeyID     string
+	bhKeySecret string
+	bhUpload    bool
+	workers     int
+	ldapUser    string
+	ldapPass    string
+	ldapCache   string
+	logger      hclog.Logger
+	sched       *scheduler.Scheduler
+	registry    *stats.Registry
+	time        bool
+	statsOut    string
+
+	vmInclude        []string
+	vmExclude        []string
+	hostInclude      []string
+	hostExclude      []string
+	clusterInclude   []string
+	clusterExclude   []string
+	datastoreInclude []string
+	datastoreExclude []string
+
+	disableRESTTagging bool
+
+	collectGuest     bool
+	guestCredentials *credentials.GuestCredentialMap
+}
+
+// splitList splits a comma-separated flag value into a slice, returning nil
+// for an empty string instead of a single empty-string element.
+func splitList(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
+// collectOnce runs a single end-to-end collection pass: resolve the domain
+// map, collect from every host into a shared graph, write it out, and
+// optionally upload it to BloodHound. It backs both the one-shot CLI mode
+// and every tick of daemon mode.
+func collectOnce(ctx context.Context, p collectOnceParams) error {
+	root := p.logger
+	if p.registry != nil {
+		p.registry.Reset()
+	}
+	resolver := directory.NewResolver(p.ldapCache, p.ldapUser, p.ldapPass)
+
 	var domainMap map[string]string
-	if *bhURL != "" && *bhKeyID != "" && *bhKeySecret != "" {
-		log.Println("Connecting to BloodHound to fetch domain map...")
-		bhClient := bloodhound.NewClient(*bhURL, *bhKeyID, *bhKeySecret)
+	if p.bhURL != "" && p.bhKeyID != "" && p.bhKeySecret != "" {
+		root.Info("connecting to BloodHound to fetch domain map")
+		bhClient := bloodhound.NewClient(p.bhURL, p.bhKeyID, p.bhKeySecret, root.Named(logging.BloodHound)).WithResolver(resolver)
 		dMap, err := bhClient.GetDomainMap()
 		if err != nil {
-			log.Printf("Warning: Failed to fetch domains from BloodHound: %v. Sync edges will be skipped.", err)
+			root.Warn("failed to fetch domains from BloodHound, sync edges will be skipped", "error", err)
 		} else {
 			domainMap = dMap
-			log.Printf("Retrieved %d domains from BloodHound", len(domainMap))
-			if *debug {
-				for nb, fqdn := range domainMap {
-					log.Printf("[DEBUG] Map: %s -> %s", nb, fqdn)
-				}
+			root.Info("retrieved domains from BloodHound", "count", len(domainMap))
+			for nb, fqdn := range domainMap {
+				root.Debug("domain map entry", "netbios", nb, "fqdn", fqdn)
 			}
 		}
 	} else {
-		log.Println("BloodHound credentials not provided. Sync edges will be skipped.")
+		root.Info("BloodHound credentials not provided, sync edges will be skipped")
 	}
 
-	hosts := strings.Split(*server, ",")
 	gb := graph.NewBuilder()
 
-	log.Printf("Starting vCenterHound by Javier Azofra Ovejero")
-	log.Printf("Targeting %d vCenter hosts", len(hosts))
+	root.Info("starting collection", "hosts", len(p.hosts), "workers", p.workers)
 
-	// 2. Collect from each vCenter (Sequentially for simplicity, or Parallel if needed)
-	// Given we are refactoring for speed, we can parallelize across vCenters too!
-	// But let's stick to parallel collection *within* each collector for stability first.
+	// Collect from each vCenter host in a bounded worker pool. gb is a
+	// single shared graph.Builder -- safe for concurrent use -- so results
+	// land directly in the merged graph with no separate merge step.
+	var eg errgroup.Group
+	eg.SetLimit(p.workers)
 
-	for _, host := range hosts {
-		host = strings.TrimSpace(host)
+	var mu sync.Mutex
+	var failed []string
+	succeeded := 0
+
+	collectorLogger := root.Named(logging.Collector)
+
+	for _, rawHost := range p.hosts {
+		host := strings.TrimSpace(rawHost)
 		if host == "" {
 			continue
 		}
 
-		cfg := config.Config{
-			Host:        host,
-			User:        *user,
-			Password:    *password,
-			Port:        *port,
-			OutputPath:  *outPath,
-			Debug:       *debug,
-			BHURL:       *bhURL,
-			BHKeyID:     *bhKeyID,
-			BHKeySecret: *bhKeySecret,
-		}
+		eg.Go(func() error {
+			user, password := p.user, p.password
+			if p.credentials != nil {
+				var err error
+				user, password, err = p.credentials.Fetch(host)
+				if err != nil {
+					collectorLogger.Error("failed to resolve credentials", "host", host, "error", err)
+					mu.Lock()
+					failed = append(failed, host)
+					mu.Unlock()
+					return nil
+				}
+			}
 
-		col := collector.NewCollector(cfg, gb, domainMap)
-		if err := col.Connect(); err != nil {
-			log.Printf("Failed to connect to %s: %v", host, err)
-			continue
-		}
+			cfg := config.Config{
+				Host:        host,
+				User:        user,
+				Password:    password,
+				Port:        p.port,
+				OutputPath:  p.outputPath,
+				Debug:       p.debug,
+				BHURL:       p.bhURL,
+				BHKeyID:     p.bhKeyID,
+				BHKeySecret: p.bhKeySecret,
 
-		col.Collect()
+				VMInclude:        p.vmInclude,
+				VMExclude:        p.vmExclude,
+				HostInclude:      p.hostInclude,
+				HostExclude:      p.hostExclude,
+				ClusterInclude:   p.clusterInclude,
+				ClusterExclude:   p.clusterExclude,
+				DatastoreInclude: p.datastoreInclude,
+				DatastoreExclude: p.datastoreExclude,
+
+				DisableRESTTagging: p.disableRESTTagging,
+
+				CollectGuest:     p.collectGuest,
+				GuestCredentials: p.guestCredentials,
+			}
+
+			col := collector.NewCollector(cfg, gb, domainMap, collectorLogger)
+			if p.sched != nil {
+				p.sched.Register(col)
+				defer p.sched.Unregister(col)
+			}
+
+			if err := col.Connect(); err != nil {
+				collectorLogger.Error("failed to connect", "host", host, "error", err)
+				mu.Lock()
+				failed = append(failed, host)
+				mu.Unlock()
+				return nil
+			}
+			// Best-effort: Go strings are immutable so this can't scrub the
+			// original memory, but it does drop our only reference to the
+			// plaintext password now that Connect has used it.
+			password = ""
+			cfg.Password = ""
+			defer col.Disconnect()
+
+			// Node/edge kind counts are a before/after snapshot of the whole
+			// shared builder, so they're approximate under concurrent
+			// multi-host collection -- good enough for -metrics-listen,
+			// not a precise per-host attribution.
+			nodesBefore, edgesBefore := countKinds(gb.Export())
+
+			if err := col.Collect(); err != nil {
+				collectorLogger.Error("failed to collect", "host", host, "error", err)
+				mu.Lock()
+				failed = append(failed, host)
+				mu.Unlock()
+				return nil
+			}
+
+			if p.registry != nil {
+				nodesAfter, edgesAfter := countKinds(gb.Export())
+				p.registry.AddHost(col.Stats.HostStats())
+				p.registry.AddNodeCounts(host, diffCounts(nodesBefore, nodesAfter))
+				p.registry.AddEdgeCounts(host, diffCounts(edgesBefore, edgesAfter))
+			}
+
+			mu.Lock()
+			succeeded++
+			mu.Unlock()
+			return nil
+		})
 	}
 
-	// 3. Export
-	log.Println("Exporting graph...")
+	_ = eg.Wait()
+
+	if len(failed) > 0 {
+		root.Warn("failed to collect from some hosts", "count", len(failed), "hosts", strings.Join(failed, ", "))
+	}
+	if succeeded == 0 {
+		return fmt.Errorf("collection failed against every targeted host")
+	}
+
+	root.Info("exporting graph")
 	data := gb.Export()
 
-	if err := output.WriteToFile(data, *outPath); err != nil {
-		log.Fatalf("Failed to write output: %v", err)
+	if err := output.NewFileSink(p.outputPath).Write(data); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
 	}
 
-	log.Printf("Success! Graph saved to %s", *outPath)
-	log.Printf("Stats: %d Nodes, %d Edges", len(data.Nodes), len(data.Edges))
+	root.Info("graph saved", "path", p.outputPath, "nodes", len(data.Nodes), "edges", len(data.Edges))
 
-	if *debug {
-		printExtendedSummary(data)
+	if p.bhUpload {
+		if p.bhURL == "" || p.bhKeyID == "" || p.bhKeySecret == "" {
+			return fmt.Errorf("-bh-upload requires -bh-url, -bh-key-id and -bh-key-secret")
+		}
+		root.Info("uploading graph directly to BloodHound")
+		bhClient := bloodhound.NewClient(p.bhURL, p.bhKeyID, p.bhKeySecret, root.Named(logging.BloodHound))
+		if err := output.NewBloodHoundSink(bhClient).Write(data); err != nil {
+			return fmt.Errorf("failed to upload graph to BloodHound: %w", err)
+		}
+		root.Info("upload complete")
 	}
-}
 
-func printExtendedSummary(data graph.GraphData) {
-	log.Println("--- Extended Summary ---")
+	if p.debug {
+		printExtendedSummary(root, data)
+	}
 
-	// Node Types
-	nodeCounts := make(map[string]int)
+	if p.registry != nil {
+		if p.time {
+			p.registry.WriteTimingTable(os.Stderr)
+		}
+		if p.statsOut != "" {
+			if err := p.registry.WriteJSON(p.statsOut); err != nil {
+				root.Error("failed to write stats report", "error", err)
+			} else {
+				root.Info("stats report written", "path", p.statsOut)
+			}
+		}
+	}
+
+	return nil
+}
+
+// countKinds tallies node and edge counts by kind.
+func countKinds(data graph.GraphData) (nodeCounts, edgeCounts map[string]int) {
+	nodeCounts = make(map[string]int)
 	for _, n := range data.Nodes {
 		for _, k := range n.Kinds {
 			nodeCounts[k]++
 		}
 	}
 
-	log.Println("Node Types:")
+	edgeCounts = make(map[string]int)
+	for _, e := range data.Edges {
+		edgeCounts[e.Kind]++
+	}
+
+	return nodeCounts, edgeCounts
+}
+
+// diffCounts returns the positive increase of each key in after relative to
+// before, dropping keys that didn't grow.
+func diffCounts(before, after map[string]int) map[string]int {
+	delta := make(map[string]int)
+	for k, v := range after {
+		if d := v - before[k]; d > 0 {
+			delta[k] = d
+		}
+	}
+	return delta
+}
+
+func printExtendedSummary(logger hclog.Logger, data graph.GraphData) {
+	nodeCounts, edgeCounts := countKinds(data)
+
 	var nodeKinds []string
 	for k := range nodeCounts {
 		nodeKinds = append(nodeKinds, k)
 	}
 	sort.Strings(nodeKinds)
 	for _, k := range nodeKinds {
-		log.Printf("  %s: %d", k, nodeCounts[k])
-	}
-
-	// Edge Types
-	edgeCounts := make(map[string]int)
-	for _, e := range data.Edges {
-		edgeCounts[e.Kind]++
+		logger.Info("node type summary", "kind", k, "count", nodeCounts[k])
 	}
 
-	log.Println("Edge Types:")
 	var edgeKinds []string
 	for k := range edgeCounts {
 		edgeKinds = append(edgeKinds, k)
 	}
 	sort.Strings(edgeKinds)
 	for _, k := range edgeKinds {
-		log.Printf("  %s: %d", k, edgeCounts[k])
+		logger.Info("edge type summary", "kind", k, "count", edgeCounts[k])
 	}
-	log.Println("------------------------")
 }
@@ -8,30 +8,66 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"math/rand"
 	"net/http"
 	"strings"
 	"time"
+
+	"vcenterhoundgo/internal/directory"
+	"vcenterhoundgo/internal/graph"
+	"vcenterhoundgo/internal/logging"
+
+	"github.com/hashicorp/go-hclog"
 )
 
+// uploadMaxRetries is the number of attempts doWithRetry makes at a request
+// before giving up, backing off exponentially between attempts after 429,
+// 5xx and clock-skew rejections.
+const uploadMaxRetries = 5
+
+// defaultChunkSize is the combined node+edge count PostGraph sends per
+// ingest request when called with chunkSize <= 0.
+const defaultChunkSize = 10000
+
 type Client struct {
 	URL       string
 	KeyID     string
 	KeySecret string
 	Client    *http.Client
+
+	// Resolver performs real LDAP NetBIOS lookups for GetDomainMap. Nil means
+	// always fall back to the "first label of the FQDN" heuristic.
+	Resolver *directory.Resolver
+
+	Logger hclog.Logger
 }
 
-func NewClient(url, keyID, keySecret string) *Client {
+// NewClient builds a BloodHound API client. logger is named "bloodhound" off
+// a default root logger if nil is passed.
+func NewClient(url, keyID, keySecret string, logger hclog.Logger) *Client {
 	// Normalize URL
 	url = strings.TrimRight(url, "/")
 
+	if logger == nil {
+		logger = logging.New("vcenterhoundgo", logging.Options{}).Named(logging.BloodHound)
+	}
+
 	return &Client{
 		URL:       url,
 		KeyID:     keyID,
 		KeySecret: keySecret,
 		Client:    &http.Client{Timeout: 30 * time.Second},
+		Logger:    logger,
 	}
 }
 
+// WithResolver attaches an LDAP-backed NetBIOS resolver, returning the
+// Client for chaining.
+func (c *Client) WithResolver(r *directory.Resolver) *Client {
+	c.Resolver = r
+	return c
+}
+
 // GetDomainMap retrieves domains from BloodHound and returns map[NetBIOS]FQDN
 func (c *Client) GetDomainMap() (map[string]string, error) {
 	req, _ := http.NewRequest("GET", c.URL+"/api/v2/available-domains", nil)
@@ -64,17 +100,257 @@ func (c *Client) GetDomainMap() (map[string]string, error) {
 	domainMap := make(map[string]string)
 	for _, d := range apiResponse.Data {
 		fqdn := strings.ToUpper(d.Name)
-		// Heuristic: NetBIOS is usually the first part of FQDN.
-		parts := strings.Split(fqdn, ".")
-		if len(parts) > 0 {
-			netbios := parts[0]
-			domainMap[netbios] = fqdn
-		}
+		domainMap[c.resolveNetBIOS(fqdn)] = fqdn
 	}
 
 	return domainMap, nil
 }
 
+// resolveNetBIOS consults the LDAP resolver if one is configured, falling
+// back to the "first label of the FQDN" heuristic when LDAP is unavailable.
+func (c *Client) resolveNetBIOS(fqdn string) string {
+	if c.Resolver != nil {
+		if netbios, err := c.Resolver.NetBIOS(fqdn); err == nil {
+			return netbios
+		}
+	}
+
+	parts := strings.Split(fqdn, ".")
+	if len(parts) > 0 {
+		return parts[0]
+	}
+	return fqdn
+}
+
+func (c *Client) startUploadJob() (string, error) {
+	resp, err := c.doWithRetry(func() (*http.Request, error) {
+		return http.NewRequest("POST", c.URL+"/api/v2/file-upload/start", nil)
+	})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Data struct {
+			ID int64 `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d", result.Data.ID), nil
+}
+
+func (c *Client) streamUploadJob(jobID string, data graph.FinalOutput) error {
+	url := fmt.Sprintf("%s/api/v2/file-upload/%s", c.URL, jobID)
+
+	// Encoded once, up front, rather than through an io.Pipe: signRequest
+	// has to HMAC the full body before the request can be sent, so a pipe
+	// here would just be drained into memory anyway for signing, on every
+	// retry attempt.
+	body, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to encode graph chunk: %w", err)
+	}
+
+	resp, err := c.doWithRetry(func() (*http.Request, error) {
+		req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		return req, nil
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return nil
+}
+
+func (c *Client) endUploadJob(jobID string) error {
+	url := fmt.Sprintf("%s/api/v2/file-upload/%s/end", c.URL, jobID)
+
+	resp, err := c.doWithRetry(func() (*http.Request, error) {
+		return http.NewRequest("POST", url, nil)
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return nil
+}
+
+// postGraphMaxChunkWait bounds how long PostGraph waits for BloodHound to
+// finish ingesting a job after it's closed.
+const postGraphMaxChunkWait = 5 * time.Minute
+
+// jobStatusComplete is the file-upload job status value BloodHound reports
+// once ingest analysis of a closed job has finished successfully.
+const jobStatusComplete = 2
+
+// jobStatusFailedStatuses covers the job status values BloodHound reports
+// for an ingest job it failed to process.
+var jobStatusFailedStatuses = map[int]bool{
+	3: true, // Cancelled
+	4: true, // TimedOut
+	5: true, // Failed
+}
+
+// PostGraph streams data into BloodHound's OpenGraph ingest endpoint in
+// chunks of at most chunkSize combined nodes+edges per request, then polls
+// the job status until BloodHound finishes processing it. Chunking across
+// several POSTs to the same job is what lets a full collection run bypass
+// both a practical per-request size limit and the need for an
+// intermediate on-disk JSON file.
+func (c *Client) PostGraph(data graph.GraphData, chunkSize int) error {
+	jobID, err := c.startUploadJob()
+	if err != nil {
+		return fmt.Errorf("failed to start ingest job: %w", err)
+	}
+
+	for _, chunk := range chunkGraphData(data, chunkSize) {
+		if err := c.streamUploadJob(jobID, graph.FinalOutput{Graph: chunk}); err != nil {
+			return fmt.Errorf("failed to stream chunk to ingest job %s: %w", jobID, err)
+		}
+	}
+
+	if err := c.endUploadJob(jobID); err != nil {
+		return fmt.Errorf("failed to close ingest job %s: %w", jobID, err)
+	}
+
+	if err := c.waitForJobCompletion(jobID); err != nil {
+		return fmt.Errorf("ingest job %s did not complete: %w", jobID, err)
+	}
+
+	c.Logger.Info("posted graph to BloodHound", "jobID", jobID, "nodes", len(data.Nodes), "edges", len(data.Edges))
+	return nil
+}
+
+// chunkGraphData splits data into a sequence of GraphData values, each
+// holding at most chunkSize nodes plus edges combined. Nodes and edges are
+// chunked independently of each other, since BloodHound's ingest endpoint
+// doesn't require an edge's endpoints to appear in the same request.
+func chunkGraphData(data graph.GraphData, chunkSize int) []graph.GraphData {
+	if chunkSize <= 0 {
+		chunkSize = defaultChunkSize
+	}
+
+	var chunks []graph.GraphData
+	for len(data.Nodes) > 0 || len(data.Edges) > 0 {
+		var chunk graph.GraphData
+
+		n := chunkSize
+		if n > len(data.Nodes) {
+			n = len(data.Nodes)
+		}
+		chunk.Nodes, data.Nodes = data.Nodes[:n], data.Nodes[n:]
+
+		remaining := chunkSize - n
+		if remaining > len(data.Edges) {
+			remaining = len(data.Edges)
+		}
+		chunk.Edges, data.Edges = data.Edges[:remaining], data.Edges[remaining:]
+
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+// waitForJobCompletion polls the file-upload job status endpoint until
+// jobID reports a terminal status, returning an error if it fails or the
+// wait exceeds postGraphMaxChunkWait.
+func (c *Client) waitForJobCompletion(jobID string) error {
+	deadline := time.Now().Add(postGraphMaxChunkWait)
+
+	for {
+		status, err := c.jobStatus(jobID)
+		if err != nil {
+			return err
+		}
+
+		if status == jobStatusComplete {
+			return nil
+		}
+		if jobStatusFailedStatuses[status] {
+			return fmt.Errorf("job reported failure status %d", status)
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out after %s waiting for completion", postGraphMaxChunkWait)
+		}
+		time.Sleep(2 * time.Second)
+	}
+}
+
+func (c *Client) jobStatus(jobID string) (int, error) {
+	resp, err := c.doWithRetry(func() (*http.Request, error) {
+		return http.NewRequest("GET", c.URL+"/api/v2/file-upload/status", nil)
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Data []struct {
+			ID     int64 `json:"id"`
+			Status int   `json:"status"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, err
+	}
+
+	for _, job := range result.Data {
+		if fmt.Sprintf("%d", job.ID) == jobID {
+			return job.Status, nil
+		}
+	}
+	return 0, fmt.Errorf("job %s not found in status list", jobID)
+}
+
+// doWithRetry signs and sends the request built by newReq, retrying with
+// exponential backoff on 429 and 5xx responses and re-signing with a fresh
+// timestamp when BloodHound rejects the request for clock skew (400 with a
+// RequestDate-related error). newReq is called again on every attempt since
+// a request body can only be read once.
+func (c *Client) doWithRetry(newReq func() (*http.Request, error)) (*http.Response, error) {
+	var lastErr error
+	for attempt := 0; attempt < uploadMaxRetries; attempt++ {
+		req, err := newReq()
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := c.Do(req)
+		if err != nil {
+			lastErr = err
+		} else if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			return resp, nil
+		} else {
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			lastErr = fmt.Errorf("status %d: %s", resp.StatusCode, string(body))
+
+			retryable := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests || isClockSkewError(resp.StatusCode, body)
+			if !retryable {
+				return nil, lastErr
+			}
+		}
+
+		backoff := time.Duration(1<<uint(attempt))*time.Second + time.Duration(rand.Intn(250))*time.Millisecond
+		c.Logger.Warn("BloodHound upload attempt failed, retrying", "attempt", attempt+1, "maxAttempts", uploadMaxRetries, "error", lastErr, "backoff", backoff)
+		time.Sleep(backoff)
+	}
+	return nil, fmt.Errorf("giving up after %d attempts: %w", uploadMaxRetries, lastErr)
+}
+
+func isClockSkewError(status int, body []byte) bool {
+	return status == http.StatusBadRequest && strings.Contains(strings.ToLower(string(body)), "requestdate")
+}
+
 // Do performs the request with authentication (Signed Request)
 func (c *Client) Do(req *http.Request) (*http.Response, error) {
 	if err := c.signRequest(req); err != nil {
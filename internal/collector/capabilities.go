@@ -0,0 +1,74 @@
+package collector
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// Capabilities records what the connected vCenter/ESXi build supports, so
+// Collect can skip phases the target doesn't have instead of failing
+// partway through them. Mirrors how version-gated API clients (e.g. etcd's
+// api/capability.go) map a negotiated server version onto a fixed feature
+// set once, at connect time.
+type Capabilities struct {
+	APIVersion    string
+	APIType       string
+	ProductLineID string
+
+	// SupportsRetrieveAllPermissions is false on builds that never
+	// implemented AuthorizationManager's bulk RetrieveAllPermissions call.
+	SupportsRetrieveAllPermissions bool
+}
+
+// String renders the negotiated capability set for a single startup log
+// line.
+func (c Capabilities) String() string {
+	return fmt.Sprintf(
+		"apiVersion=%s apiType=%s productLine=%s retrieveAllPermissions=%t",
+		c.APIVersion, c.APIType, c.ProductLineID, c.SupportsRetrieveAllPermissions,
+	)
+}
+
+// negotiateCapabilities maps a connected client's About info to a
+// Capabilities set. Unparseable or missing versions are treated as the
+// oldest supported baseline so collection degrades instead of failing.
+func negotiateCapabilities(about types.AboutInfo) Capabilities {
+	major, minor := parseAPIVersion(about.ApiVersion)
+	atLeast := func(wantMajor, wantMinor int) bool {
+		if major != wantMajor {
+			return major > wantMajor
+		}
+		return minor >= wantMinor
+	}
+
+	return Capabilities{
+		APIVersion:    about.ApiVersion,
+		APIType:       about.ApiType,
+		ProductLineID: about.ProductLineId,
+
+		// RetrieveAllPermissions has been part of AuthorizationManager since
+		// vSphere API 4.0 -- every build old enough to still be reachable
+		// supports it. An unparseable/missing ApiVersion can't be compared
+		// against that baseline at all, so it's treated as supported rather
+		// than as version 0.0, which would incorrectly fail the atLeast(4,0)
+		// check for the oldest-baseline case this is meant to degrade to.
+		SupportsRetrieveAllPermissions: major == 0 && minor == 0 || atLeast(4, 0),
+	}
+}
+
+// parseAPIVersion splits a dot-separated API version string ("7.0.3.0")
+// into its major and minor components, defaulting to 0 on anything
+// unparseable.
+func parseAPIVersion(v string) (major, minor int) {
+	parts := strings.SplitN(v, ".", 3)
+	if len(parts) > 0 {
+		major, _ = strconv.Atoi(parts[0])
+	}
+	if len(parts) > 1 {
+		minor, _ = strconv.Atoi(parts[1])
+	}
+	return major, minor
+}
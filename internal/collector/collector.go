@@ -0,0 +1,209 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync/atomic"
+
+	"vcenterhoundgo/internal/config"
+	"vcenterhoundgo/internal/graph"
+	"vcenterhoundgo/internal/logging"
+	"vcenterhoundgo/internal/stats"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/vim25/soap"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// Collector holds the state for a single vCenter collection run. GraphBuilder
+// is shared across Collectors when collection is run in parallel across
+// multiple hosts, so all mutation goes through graph.Builder's own locking.
+type Collector struct {
+	Config       config.Config
+	Client       *govmomi.Client
+	GraphBuilder *graph.Builder
+	DomainMap    map[string]string
+	Context      context.Context
+	Roles        map[int32]types.AuthorizationRole
+	Privileges   map[string]types.AuthorizationPrivilege
+	Logger       hclog.Logger
+
+	// Permissions caches the result of CollectPermissions' bulk
+	// RetrieveAllPermissions call so CollectGroupMemberships doesn't need to
+	// issue a second one to find which principals are groups.
+	Permissions []types.Permission
+
+	// Capabilities is populated by Connect from ServiceContent.About and
+	// determines which collection phases Collect runs.
+	Capabilities Capabilities
+
+	// Stats records per-phase timing/volume for -time, -stats-out and
+	// -metrics-listen.
+	Stats *stats.Recorder
+
+	// apiCalls counts every SOAP call made through Client's RoundTripper
+	// since Connect wrapped it. Read with atomic so runPhase can snapshot it
+	// from Collect while Connect's own goroutine (there is only one per
+	// Collector, but atomics cost nothing here) is mid-call.
+	apiCalls int64
+
+	// Inventory-path filters, compiled once from Config so CollectInfrastructure
+	// doesn't re-parse patterns for every node it visits.
+	vmFilter        *pathFilter
+	hostFilter      *pathFilter
+	clusterFilter   *pathFilter
+	datastoreFilter *pathFilter
+
+	// vmCreated records, per VM reference, whether CollectInfrastructure's
+	// first encounter with it created a graph node. VMs are reachable both
+	// through their VM-folder path and through their host's Vm list, and
+	// only the folder path (processed first) decides vmFilter inclusion --
+	// the host-list encounter just links to whatever the folder pass did.
+	vmCreated map[types.ManagedObjectReference]bool
+}
+
+// NewCollector creates a new Collector targeting a single vCenter host. gb is
+// typically shared across every host in a run so the exported graph is a
+// single merged result. If logger is nil, a default root logger's
+// Named(logging.Collector) sub-logger is used; otherwise the caller's logger
+// (typically root.Named(logging.Collector)) is used as given. Either way a
+// "host" field is added so output can be filtered by host/subsystem.
+func NewCollector(cfg config.Config, gb *graph.Builder, domainMap map[string]string, logger hclog.Logger) *Collector {
+	if logger == nil {
+		logger = logging.New("vcenterhoundgo", logging.Options{}).Named(logging.Collector)
+	}
+	return &Collector{
+		Config:       cfg,
+		GraphBuilder: gb,
+		DomainMap:    domainMap,
+		Context:      context.Background(),
+		Roles:        make(map[int32]types.AuthorizationRole),
+		Privileges:   make(map[string]types.AuthorizationPrivilege),
+		Logger:       logger.With("host", cfg.Host),
+		Stats:        stats.NewRecorder(cfg.Host),
+
+		vmFilter:        newPathFilter(cfg.VMInclude, cfg.VMExclude),
+		hostFilter:      newPathFilter(cfg.HostInclude, cfg.HostExclude),
+		clusterFilter:   newPathFilter(cfg.ClusterInclude, cfg.ClusterExclude),
+		datastoreFilter: newPathFilter(cfg.DatastoreInclude, cfg.DatastoreExclude),
+
+		vmCreated: make(map[types.ManagedObjectReference]bool),
+	}
+}
+
+// Connect establishes the SOAP session to vCenter.
+func (c *Collector) Connect() error {
+	done := c.Stats.Phase("Connect")
+	defer func() { done(0, 1) }()
+
+	u, err := url.Parse(fmt.Sprintf("https://%s:%d/sdk", c.Config.Host, c.Config.Port))
+	if err != nil {
+		return err
+	}
+
+	u.User = url.UserPassword(c.Config.User, c.Config.Password)
+
+	c.Client, err = govmomi.NewClient(c.Context, u, true)
+	if err != nil {
+		if strings.Contains(err.Error(), "incorrect user name or password") {
+			return fmt.Errorf("authentication failed: incorrect user name or password for %s@%s", c.Config.User, c.Config.Host)
+		}
+		return err
+	}
+
+	// Every subsequent SOAP call goes through RoundTripper, so wrapping it
+	// here is enough to count API calls for every later phase without
+	// touching the phases themselves.
+	c.Client.RoundTripper = &countingRoundTripper{next: c.Client.RoundTripper, count: &c.apiCalls}
+
+	c.Capabilities = negotiateCapabilities(c.Client.ServiceContent.About)
+
+	return nil
+}
+
+// countingRoundTripper wraps a soap.RoundTripper to count every SOAP call
+// made through it, so Collect's phases can report accurate API call counts
+// by snapshotting count before and after each phase.
+type countingRoundTripper struct {
+	next  soap.RoundTripper
+	count *int64
+}
+
+func (rt *countingRoundTripper) RoundTrip(ctx context.Context, req, res soap.HasFault) error {
+	atomic.AddInt64(rt.count, 1)
+	return rt.next.RoundTrip(ctx, req, res)
+}
+
+// Disconnect closes the vCenter session.
+func (c *Collector) Disconnect() {
+	if c.Client != nil {
+		_ = c.Client.Logout(c.Context)
+	}
+}
+
+// Collect orchestrates every collection phase against the already-connected client.
+func (c *Collector) Collect() error {
+	c.Logger.Info("connected")
+	c.Logger.Info("negotiated vCenter capabilities", "capabilities", c.Capabilities.String())
+
+	c.Logger.Info("collecting infrastructure data")
+	if err := c.runPhase("CollectInfrastructure", c.CollectInfrastructure); err != nil {
+		c.Logger.Error("error collecting infrastructure", "error", err)
+	}
+
+	if c.Capabilities.SupportsRetrieveAllPermissions {
+		c.Logger.Info("collecting permissions data")
+		if err := c.runPhase("CollectPermissions", c.CollectPermissions); err != nil {
+			c.Logger.Error("error collecting permissions", "error", err)
+		}
+	} else {
+		c.Logger.Warn("skipping permissions collection, target does not support RetrieveAllPermissions", "apiVersion", c.Capabilities.APIVersion)
+	}
+
+	c.Logger.Info("collecting group memberships")
+	if err := c.runPhase("CollectGroupMemberships", c.CollectGroupMemberships); err != nil {
+		c.Logger.Error("error collecting group memberships", "error", err)
+	}
+
+	c.Logger.Info("collecting custom attributes and vAPI tags")
+	if err := c.runPhase("CollectMetadata", c.CollectMetadata); err != nil {
+		c.Logger.Error("error collecting metadata", "error", err)
+	}
+
+	if c.Config.CollectGuest {
+		c.Logger.Info("collecting in-guest process and account data")
+		if err := c.runPhase("CollectGuestData", c.CollectGuestData); err != nil {
+			c.Logger.Error("error collecting guest data", "error", err)
+		}
+	}
+
+	return nil
+}
+
+// runPhase times fn as a single named phase, recording its duration plus the
+// graph nodes/edges it added and the vCenter API calls it made. The
+// node/edge counts are a before/after snapshot of the whole GraphBuilder, so
+// they're only exact when nothing else is writing to it concurrently; under
+// parallel multi-host collection they're a reasonable approximation, not an
+// exact per-phase attribution.
+func (c *Collector) runPhase(name string, fn func() error) error {
+	nodesBefore, edgesBefore := c.GraphBuilder.Counts()
+	apiBefore := atomic.LoadInt64(&c.apiCalls)
+
+	done := c.Stats.Phase(name)
+	err := fn()
+
+	nodesAfter, edgesAfter := c.GraphBuilder.Counts()
+	apiAfter := atomic.LoadInt64(&c.apiCalls)
+
+	done((nodesAfter-nodesBefore)+(edgesAfter-edgesBefore), int(apiAfter-apiBefore))
+	return err
+}
+
+// getID returns the MOID of a managed object reference.
+func getID(ref types.ManagedObjectReference) string {
+	return ref.Value
+}
@@ -0,0 +1,136 @@
+package collector
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// processVMDevices walks vm's virtual hardware and emits a node per disk,
+// NIC and controller, plus the edges that tie them back to the VM and to
+// their datastore/network/controller backing. This gives path queries
+// finer-grained reasoning (VMs sharing a datastore file, a portgroup, or a
+// SCSI bus) than the VM-level USES_DATASTORE/USES_NETWORK edges alone can
+// express.
+func (c *Collector) processVMDevices(vm mo.VirtualMachine, vmID string, snap *inventorySnapshot) {
+	if vm.Config == nil {
+		return
+	}
+
+	controllerIDByKey := make(map[int32]string)
+	for _, dev := range vm.Config.Hardware.Device {
+		kind, ok := controllerKind(dev)
+		if !ok {
+			continue
+		}
+		vdev := dev.GetVirtualDevice()
+		ctrlID := fmt.Sprintf("vmcontroller:%s:%s:%d", c.Config.Host, getID(vm.Reference()), vdev.Key)
+		c.GraphBuilder.EnsureNode([]string{"VirtualController"}, ctrlID, map[string]interface{}{
+			"key":  vdev.Key,
+			"type": kind,
+		})
+		c.GraphBuilder.AddEdge("HAS_DEVICE", vmID, ctrlID, nil)
+		controllerIDByKey[vdev.Key] = ctrlID
+	}
+
+	for _, dev := range vm.Config.Hardware.Device {
+		if disk, ok := dev.(*types.VirtualDisk); ok {
+			c.processVMDisk(disk, vm, vmID, controllerIDByKey, snap)
+			continue
+		}
+		if nic, ok := dev.(types.BaseVirtualEthernetCard); ok {
+			c.processVMNic(dev, nic.GetVirtualEthernetCard(), vm, vmID, controllerIDByKey)
+		}
+	}
+}
+
+// controllerKind reports the VirtualController node kind for dev, or false
+// if dev isn't one of the SCSI/NVMe/IDE controller types.
+func controllerKind(dev types.BaseVirtualDevice) (string, bool) {
+	switch dev.(type) {
+	case types.BaseVirtualSCSIController:
+		return "VirtualSCSIController", true
+	case *types.VirtualNVMEController:
+		return "VirtualNVMEController", true
+	case *types.VirtualIDEController:
+		return "VirtualIDEController", true
+	default:
+		return "", false
+	}
+}
+
+func (c *Collector) processVMDisk(disk *types.VirtualDisk, vm mo.VirtualMachine, vmID string, controllerIDByKey map[int32]string, snap *inventorySnapshot) {
+	diskID := fmt.Sprintf("vmdisk:%s:%s:%d", c.Config.Host, getID(vm.Reference()), disk.Key)
+	props := map[string]interface{}{
+		"key":          disk.Key,
+		"capacityInKB": disk.CapacityInKB,
+	}
+
+	var fileName string
+	if backing, ok := disk.Backing.(*types.VirtualDiskFlatVer2BackingInfo); ok {
+		fileName = backing.FileName
+		if backing.ThinProvisioned != nil {
+			props["thinProvisioned"] = *backing.ThinProvisioned
+		}
+	}
+	if fileName != "" {
+		props["fileName"] = fileName
+	}
+
+	c.GraphBuilder.EnsureNode([]string{"VirtualDisk"}, diskID, props)
+	c.GraphBuilder.AddEdge("HAS_DEVICE", vmID, diskID, nil)
+
+	if dsName, _, ok := parseDatastorePath(fileName); ok {
+		if dsID, ok := snap.datastoreIDByName[dsName]; ok {
+			c.GraphBuilder.AddEdge("ATTACHED_TO_DATASTORE", diskID, dsID, nil)
+		}
+	}
+
+	if ctrlID, ok := controllerIDByKey[disk.ControllerKey]; ok {
+		c.GraphBuilder.AddEdge("CONTROLLED_BY", diskID, ctrlID, nil)
+	}
+}
+
+func (c *Collector) processVMNic(dev types.BaseVirtualDevice, nic *types.VirtualEthernetCard, vm mo.VirtualMachine, vmID string, controllerIDByKey map[int32]string) {
+	nicID := fmt.Sprintf("vmnic:%s:%s:%d", c.Config.Host, getID(vm.Reference()), nic.Key)
+	adapterType := strings.TrimPrefix(fmt.Sprintf("%T", dev), "*types.")
+
+	c.GraphBuilder.EnsureNode([]string{"VirtualEthernetCard"}, nicID, map[string]interface{}{
+		"key":         nic.Key,
+		"macAddress":  nic.MacAddress,
+		"adapterType": adapterType,
+	})
+	c.GraphBuilder.AddEdge("HAS_DEVICE", vmID, nicID, nil)
+
+	switch backing := nic.Backing.(type) {
+	case *types.VirtualEthernetCardNetworkBackingInfo:
+		if backing.Network != nil {
+			netID := fmt.Sprintf("network:%s:%s", c.Config.Host, getID(*backing.Network))
+			c.GraphBuilder.AddEdge("CONNECTED_TO", nicID, netID, nil)
+		}
+	case *types.VirtualEthernetCardDistributedVirtualPortBackingInfo:
+		if backing.Port.PortgroupKey != "" {
+			netID := fmt.Sprintf("network:%s:%s", c.Config.Host, backing.Port.PortgroupKey)
+			c.GraphBuilder.AddEdge("CONNECTED_TO", nicID, netID, nil)
+		}
+	}
+
+	if ctrlID, ok := controllerIDByKey[nic.ControllerKey]; ok {
+		c.GraphBuilder.AddEdge("CONTROLLED_BY", nicID, ctrlID, nil)
+	}
+}
+
+// parseDatastorePath splits a vSphere datastore-path filename like
+// "[datastore1] myvm/myvm.vmdk" into its datastore name and relative path.
+func parseDatastorePath(fileName string) (datastore string, path string, ok bool) {
+	if !strings.HasPrefix(fileName, "[") {
+		return "", "", false
+	}
+	end := strings.Index(fileName, "]")
+	if end < 0 {
+		return "", "", false
+	}
+	return fileName[1:end], strings.TrimSpace(fileName[end+1:]), true
+}
@@ -0,0 +1,95 @@
+package collector
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// pathFilter decides whether an inventory path like "/DC1/vm/prod/web-01"
+// should be collected, given an include allow list and an exclude deny
+// list. Patterns are "/"-separated and support "*" (a single path segment),
+// "**" (any number of segments, including zero), and the character classes
+// path/filepath.Match already understands (e.g. "[abc]", "cluster-[0-9]").
+type pathFilter struct {
+	includes [][]string
+	excludes [][]string
+}
+
+// newPathFilter compiles includes/excludes once so Allows can be called
+// once per node during traversal without re-parsing patterns.
+func newPathFilter(includes, excludes []string) *pathFilter {
+	return &pathFilter{includes: compilePatterns(includes), excludes: compilePatterns(excludes)}
+}
+
+// compilePatterns splits each pattern into its path segments up front.
+func compilePatterns(patterns []string) [][]string {
+	compiled := make([][]string, len(patterns))
+	for i, p := range patterns {
+		compiled[i] = splitPath(p)
+	}
+	return compiled
+}
+
+// Allows reports whether path should be collected: it must not match any
+// exclude pattern, and, if any include patterns are configured, it must
+// match at least one of them.
+func (f *pathFilter) Allows(path string) bool {
+	if matchesAny(f.excludes, path) {
+		return false
+	}
+	if len(f.includes) == 0 {
+		return true
+	}
+	return matchesAny(f.includes, path)
+}
+
+func matchesAny(patterns [][]string, path string) bool {
+	segs := splitPath(path)
+	for _, p := range patterns {
+		if matchSegments(p, segs) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGlobPath matches a "/"-separated path against pattern segment by
+// segment, expanding "**" recursively since path/filepath.Match has no
+// notion of a multi-segment wildcard.
+func matchGlobPath(pattern, path string) bool {
+	return matchSegments(splitPath(pattern), splitPath(path))
+}
+
+func splitPath(p string) []string {
+	p = strings.Trim(p, "/")
+	if p == "" {
+		return nil
+	}
+	return strings.Split(p, "/")
+}
+
+func matchSegments(pattern, path []string) bool {
+	if len(pattern) == 0 {
+		return len(path) == 0
+	}
+
+	if pattern[0] == "**" {
+		if matchSegments(pattern[1:], path) {
+			return true
+		}
+		if len(path) == 0 {
+			return false
+		}
+		return matchSegments(pattern, path[1:])
+	}
+
+	if len(path) == 0 {
+		return false
+	}
+
+	ok, err := filepath.Match(pattern[0], path[0])
+	if err != nil || !ok {
+		return false
+	}
+	return matchSegments(pattern[1:], path[1:])
+}
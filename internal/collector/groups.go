@@ -0,0 +1,125 @@
+package collector
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/vmware/govmomi/vim25/methods"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// CollectGroupMemberships resolves AD group membership for every principal
+// that was granted a permission during CollectPermissions.
+func (c *Collector) CollectGroupMemberships() error {
+	userDirRef := c.Client.ServiceContent.UserDirectory
+	if userDirRef == nil {
+		return fmt.Errorf("no user directory found")
+	}
+
+	var userDir mo.UserDirectory
+	_ = c.Client.RetrieveOne(c.Context, *userDirRef, []string{"domainList"}, &userDir)
+
+	domains := userDir.DomainList
+
+	// Reuse the permissions CollectPermissions already retrieved instead of
+	// issuing a second RetrieveAllPermissions call. If that phase didn't run
+	// (no AuthorizationManager, or it failed), there's nothing to find group
+	// principals from.
+	if c.Client.ServiceContent.AuthorizationManager == nil {
+		return fmt.Errorf("no authorization manager found")
+	}
+
+	groupsWithPermissions := make(map[string]bool)
+	for _, perm := range c.Permissions {
+		if perm.Group {
+			groupsWithPermissions[perm.Principal] = true
+		}
+	}
+
+	for groupPrincipal := range groupsWithPermissions {
+		parentGID := fmt.Sprintf("group:%s:%s", c.Config.Host, groupPrincipal)
+
+		for _, domain := range domains {
+			c.findMembers(groupPrincipal, domain, parentGID, true)
+			c.findMembers(groupPrincipal, domain, parentGID, false)
+		}
+
+		if strings.Contains(groupPrincipal, "\\") {
+			parts := strings.SplitN(groupPrincipal, "\\", 2)
+			if len(parts) == 2 {
+				groupNameOnly := parts[1]
+				for _, domain := range domains {
+					c.findMembers(groupNameOnly, domain, parentGID, true)
+					c.findMembers(groupNameOnly, domain, parentGID, false)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func (c *Collector) findMembers(groupPrincipal string, domain string, parentGID string, findUsers bool) {
+	req := types.RetrieveUserGroups{
+		This:           *c.Client.ServiceContent.UserDirectory,
+		Domain:         domain,
+		SearchStr:      "",
+		ExactMatch:     false,
+		FindUsers:      findUsers,
+		FindGroups:     !findUsers,
+		BelongsToGroup: groupPrincipal,
+	}
+
+	res, err := methods.RetrieveUserGroups(c.Context, c.Client.Client, &req)
+	if err != nil {
+		c.Logger.Error("error retrieving user groups", "group", groupPrincipal, "domain", domain, "error", err)
+		return
+	}
+
+	for _, baseResult := range res.Returnval {
+		result, ok := baseResult.(*types.UserSearchResult)
+		if !ok {
+			continue
+		}
+
+		domain, username := c.parsePrincipal(result.Principal)
+
+		var id string
+		var kinds []string
+		var isGroup bool
+
+		if findUsers {
+			id = fmt.Sprintf("user:%s:%s", c.Config.Host, result.Principal)
+			kinds = []string{"User"}
+			isGroup = false
+		} else {
+			id = fmt.Sprintf("group:%s:%s", c.Config.Host, result.Principal)
+			kinds = []string{"Group"}
+			isGroup = true
+		}
+
+		c.GraphBuilder.EnsureNode(kinds, id, map[string]interface{}{
+			"name":     result.Principal,
+			"domain":   domain,
+			"username": username,
+			"isGroup":  isGroup,
+		})
+
+		c.GraphBuilder.AddEdge("MEMBER_OF", id, parentGID, nil)
+	}
+}
+
+// parsePrincipal splits a "DOMAIN\user" or "user@domain" principal into its
+// domain and username parts.
+func (c *Collector) parsePrincipal(principal string) (string, string) {
+	if strings.Contains(principal, "\\") {
+		parts := strings.SplitN(principal, "\\", 2)
+		return parts[0], parts[1]
+	}
+	if strings.Contains(principal, "@") {
+		parts := strings.SplitN(principal, "@", 2)
+		return parts[1], parts[0]
+	}
+	return "", principal
+}
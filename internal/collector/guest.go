@@ -0,0 +1,239 @@
+package collector
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/vmware/govmomi/guest"
+	"github.com/vmware/govmomi/view"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// CollectGuestData lists running processes -- and, on Windows guests, local
+// accounts -- inside every powered-on VM with VMware Tools running, using a
+// guest credential resolved per-VM from Config.GuestCredentials. It's
+// opt-in (Config.CollectGuest) since it needs a real guest login for every
+// VM it touches and leaves an audit trail inside the guest itself.
+func (c *Collector) CollectGuestData() error {
+	if c.Config.GuestCredentials == nil {
+		return fmt.Errorf("guest collection enabled but no -guest-credentials file configured")
+	}
+
+	m := view.NewManager(c.Client.Client)
+	v, err := m.CreateContainerView(c.Context, c.Client.ServiceContent.RootFolder, []string{"VirtualMachine"}, true)
+	if err != nil {
+		return err
+	}
+	defer v.Destroy(c.Context)
+
+	var vms []mo.VirtualMachine
+	if err := v.Retrieve(c.Context, []string{"VirtualMachine"}, []string{"name", "runtime", "guest"}, &vms); err != nil {
+		return fmt.Errorf("failed to bulk-retrieve VMs for guest collection: %w", err)
+	}
+
+	processed := 0
+	for _, vm := range vms {
+		if vm.Runtime.PowerState != types.VirtualMachinePowerStatePoweredOn {
+			continue
+		}
+		if vm.Guest == nil || vm.Guest.ToolsRunningStatus != string(types.VirtualMachineToolsRunningStatusGuestToolsRunning) {
+			continue
+		}
+
+		osFamily := vm.Guest.GuestFamily
+		user, pass, ok := c.Config.GuestCredentials.Resolve(vm.Name, osFamily)
+		if !ok {
+			c.Logger.Debug("no guest credential matched, skipping", "vm", vm.Name)
+			continue
+		}
+
+		if err := c.collectGuestVM(vm, user, pass, osFamily); err != nil {
+			c.Logger.Warn("failed to collect guest data", "vm", vm.Name, "error", err)
+			continue
+		}
+		processed++
+	}
+
+	c.Logger.Info("collected in-guest data", "vms", processed)
+	return nil
+}
+
+// collectGuestVM emits GuestProcess/LocalAccount nodes for a single VM.
+// user/pass are never logged -- only the outcome (counts, errors) is.
+func (c *Collector) collectGuestVM(vm mo.VirtualMachine, user, pass, osFamily string) error {
+	vmID := fmt.Sprintf("vm:%s:%s", c.Config.Host, getID(vm.Reference()))
+	auth := &types.NamePasswordAuthentication{Username: user, Password: pass}
+
+	opMan := guest.NewOperationsManager(c.Client.Client, vm.Reference())
+	procMan, err := opMan.ProcessManager(c.Context)
+	if err != nil {
+		return fmt.Errorf("failed to get guest process manager: %w", err)
+	}
+
+	procs, err := procMan.ListProcesses(c.Context, auth, nil)
+	if err != nil {
+		return fmt.Errorf("failed to list guest processes: %w", err)
+	}
+
+	localAccounts := make(map[string]bool)
+	if osFamily == string(types.VirtualMachineGuestOsFamilyWindowsGuest) {
+		accounts, err := c.listWindowsLocalAccounts(opMan, auth)
+		if err != nil {
+			c.Logger.Debug("failed to enumerate local accounts", "vm", vm.Name, "error", err)
+		}
+		for _, a := range accounts {
+			localAccounts[a] = true
+			acctID := fmt.Sprintf("localaccount:%s:%s", getID(vm.Reference()), a)
+			c.GraphBuilder.EnsureNode([]string{"LocalAccount"}, acctID, map[string]interface{}{"name": a})
+			c.GraphBuilder.AddEdge("HAS_LOCAL_ACCOUNT", vmID, acctID, nil)
+		}
+	}
+
+	for _, p := range procs {
+		procID := fmt.Sprintf("guestproc:%s:%d", getID(vm.Reference()), p.Pid)
+		c.GraphBuilder.EnsureNode([]string{"GuestProcess"}, procID, map[string]interface{}{
+			"name":      p.Name,
+			"cmdLine":   p.CmdLine,
+			"owner":     p.Owner,
+			"startTime": p.StartTime,
+		})
+		c.GraphBuilder.AddEdge("HAS_PROCESS", vmID, procID, nil)
+
+		if account := localAccountName(p.Owner); localAccounts[account] {
+			acctID := fmt.Sprintf("localaccount:%s:%s", getID(vm.Reference()), account)
+			c.GraphBuilder.AddEdge("RUNS_AS", procID, acctID, nil)
+		}
+	}
+
+	return nil
+}
+
+// localAccountName strips a "DOMAIN\user" or "NT AUTHORITY\SYSTEM"-style
+// process owner down to the bare account name, to match against the names
+// listWindowsLocalAccounts discovers.
+func localAccountName(owner string) string {
+	if i := strings.LastIndex(owner, `\`); i >= 0 {
+		return owner[i+1:]
+	}
+	return owner
+}
+
+// listWindowsLocalAccounts runs "net user" inside a Windows guest and
+// parses its column-aligned output, following the same run-in-guest,
+// redirect-to-file, download-the-result pattern govmomi's own esxcli
+// executor uses for running shell commands against a remote target.
+func (c *Collector) listWindowsLocalAccounts(opMan *guest.OperationsManager, auth types.BaseGuestAuthentication) ([]string, error) {
+	procMan, err := opMan.ProcessManager(c.Context)
+	if err != nil {
+		return nil, err
+	}
+	fileMan, err := opMan.FileManager(c.Context)
+	if err != nil {
+		return nil, err
+	}
+
+	outPath, err := fileMan.CreateTemporaryFile(c.Context, auth, "vchoundgo", ".txt", "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary file in guest: %w", err)
+	}
+	defer fileMan.DeleteFile(c.Context, auth, outPath)
+
+	spec := &types.GuestProgramSpec{
+		ProgramPath: "C:\\Windows\\System32\\cmd.exe",
+		Arguments:   fmt.Sprintf("/c net user > %q 2>&1", outPath),
+	}
+	pid, err := procMan.StartProgram(c.Context, auth, spec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start net user in guest: %w", err)
+	}
+
+	if err := c.waitForGuestProcess(procMan, auth, pid); err != nil {
+		return nil, err
+	}
+
+	data, err := c.downloadGuestFile(fileMan, auth, outPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download net user output: %w", err)
+	}
+
+	return parseNetUserOutput(data), nil
+}
+
+// waitForGuestProcess polls ListProcesses until pid reports an EndTime,
+// guest operations have no event/callback API to block on completion.
+func (c *Collector) waitForGuestProcess(procMan *guest.ProcessManager, auth types.BaseGuestAuthentication, pid int64) error {
+	ctx, cancel := context.WithTimeout(c.Context, 60*time.Second)
+	defer cancel()
+
+	for {
+		procs, err := procMan.ListProcesses(ctx, auth, []int64{pid})
+		if err != nil {
+			return fmt.Errorf("failed to poll guest process %d: %w", pid, err)
+		}
+		if len(procs) == 0 || procs[0].EndTime != nil {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out waiting for guest process %d to finish", pid)
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+func (c *Collector) downloadGuestFile(fileMan *guest.FileManager, auth types.BaseGuestAuthentication, guestPath string) ([]byte, error) {
+	info, err := fileMan.InitiateFileTransferFromGuest(c.Context, auth, guestPath)
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := c.Client.Client.ParseURL(info.Url)
+	if err != nil {
+		return nil, err
+	}
+
+	rc, _, err := c.Client.Client.Download(c.Context, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(rc); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// parseNetUserOutput extracts account names from "net user"'s
+// column-aligned, locale-dependent output: a header, a column of usernames
+// in groups of up to four per line, and a footer starting with "The
+// command completed successfully.". Lines that don't look like a row of
+// account names are skipped rather than guessed at.
+func parseNetUserOutput(data []byte) []string {
+	var accounts []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	inBody := false
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, "----"):
+			inBody = true
+			continue
+		case trimmed == "" || strings.HasPrefix(trimmed, "The command completed"):
+			inBody = false
+			continue
+		case !inBody:
+			continue
+		}
+		accounts = append(accounts, strings.Fields(line)...)
+	}
+	return accounts
+}
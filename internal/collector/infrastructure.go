@@ -0,0 +1,537 @@
+package collector
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// CollectInfrastructure walks the inventory tree (datacenters, folders,
+// clusters, hosts, VMs, datastores, networks) and populates the shared
+// graph. It fetches every object it needs up front with
+// fetchInventorySnapshot, so the traversal below is pure in-memory map
+// lookups rather than one RetrieveOne round trip per object.
+//
+// As it descends it builds each node's inventory path (e.g.
+// "/DC1/vm/prod/web-01") and checks it against the VM/Host/Cluster/Datastore
+// include and exclude filters compiled in NewCollector, so a node outside
+// the configured scope is skipped along with everything under it.
+func (c *Collector) CollectInfrastructure() error {
+	vcenterID := fmt.Sprintf("vcenter:%s", c.Config.Host)
+	c.GraphBuilder.EnsureNode([]string{"vCenter"}, vcenterID, map[string]interface{}{"name": c.Config.Host})
+	c.Logger.Info("added vCenter node", "vcenter", c.Config.Host)
+
+	snap, err := c.fetchInventorySnapshot()
+	if err != nil {
+		return err
+	}
+	c.Logger.Debug("fetched inventory snapshot",
+		"folders", len(snap.folders), "datacenters", len(snap.datacenters),
+		"clusters", len(snap.clusters), "hosts", len(snap.hosts),
+		"vms", len(snap.vms), "datastores", len(snap.datastores),
+		"networks", len(snap.networks), "resourcePools", len(snap.resourcePools))
+
+	rootRef := c.Client.ServiceContent.RootFolder
+	rootFolder, ok := snap.folders[rootRef]
+	if !ok {
+		return fmt.Errorf("root folder %s missing from inventory snapshot", rootRef.Value)
+	}
+
+	rootID := fmt.Sprintf("folder:%s:%s", c.Config.Host, getID(rootFolder.Reference()))
+	c.GraphBuilder.EnsureNode([]string{"RootFolder", "Folder"}, rootID, map[string]interface{}{
+		"name": rootFolder.Name,
+		"moid": getID(rootFolder.Reference()),
+	})
+	c.GraphBuilder.AddEdge("CONTAINS", vcenterID, rootID, nil)
+
+	for _, child := range rootFolder.ChildEntity {
+		switch child.Type {
+		case "Datacenter":
+			if err := c.processDatacenter(child, rootID, "", snap); err != nil {
+				c.Logger.Error("error processing datacenter", "error", err)
+			}
+		case "Folder":
+			if err := c.processFolder(child, rootID, "", snap); err != nil {
+				c.Logger.Error("error processing folder", "error", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// dcRoot returns the datacenter-rooted prefix of path, e.g.
+// "/DC1/host/cluster1" -> "/DC1". Datastores are reached via reference
+// lists from clusters, hosts and VMs rather than a single parent folder, so
+// this recovers the datacenter they belong to for DatastoreInclude/Exclude
+// matching.
+func dcRoot(path string) string {
+	segs := splitPath(path)
+	if len(segs) == 0 {
+		return ""
+	}
+	return "/" + segs[0]
+}
+
+func (c *Collector) processFolder(ref types.ManagedObjectReference, parentID string, parentPath string, snap *inventorySnapshot) error {
+	folder, ok := snap.folders[ref]
+	if !ok {
+		return fmt.Errorf("folder %s missing from inventory snapshot", ref.Value)
+	}
+
+	folderID := fmt.Sprintf("folder:%s:%s", c.Config.Host, getID(folder.Reference()))
+	c.GraphBuilder.EnsureNode([]string{"Folder"}, folderID, map[string]interface{}{
+		"name": folder.Name,
+		"moid": getID(folder.Reference()),
+	})
+	c.GraphBuilder.AddEdge("CONTAINS", parentID, folderID, nil)
+
+	path := parentPath + "/" + folder.Name
+	for _, child := range folder.ChildEntity {
+		switch child.Type {
+		case "Datacenter":
+			c.processDatacenter(child, folderID, path, snap)
+		case "Folder":
+			c.processFolder(child, folderID, path, snap)
+		case "VirtualMachine":
+			c.processVM(child, folderID, path, "CONTAINS", snap)
+		}
+	}
+	return nil
+}
+
+func (c *Collector) processDatacenter(ref types.ManagedObjectReference, parentID string, parentPath string, snap *inventorySnapshot) error {
+	dc, ok := snap.datacenters[ref]
+	if !ok {
+		return fmt.Errorf("datacenter %s missing from inventory snapshot", ref.Value)
+	}
+
+	dcID := fmt.Sprintf("datacenter:%s:%s", c.Config.Host, getID(dc.Reference()))
+	c.GraphBuilder.EnsureNode([]string{"Datacenter"}, dcID, map[string]interface{}{
+		"name": dc.Name,
+		"moid": getID(dc.Reference()),
+	})
+	c.GraphBuilder.AddEdge("CONTAINS", parentID, dcID, nil)
+
+	c.Logger.Info("processing datacenter", "datacenter", dc.Name)
+
+	dcPath := parentPath + "/" + dc.Name
+
+	// VM folder is processed before host folder: a VM's folder path is its
+	// canonical inventory path, so it has to be the one that decides
+	// vmFilter inclusion. processHostCommon's own pass over host.Vm then
+	// just links already-created VMs to their host.
+	if err := c.processVMFolder(dc.VmFolder, dcID, dcPath, snap); err != nil {
+		c.Logger.Error("error processing VM folder", "datacenter", dc.Name, "error", err)
+	}
+
+	if err := c.processHostFolder(dc.HostFolder, dcID, dcPath, snap); err != nil {
+		c.Logger.Error("error processing host folder", "datacenter", dc.Name, "error", err)
+	}
+
+	for _, ds := range dc.Datastore {
+		c.processDatastore(ds, dcID, dcPath+"/datastore", snap)
+	}
+
+	for _, net := range dc.Network {
+		c.processNetwork(net, dcID, snap)
+	}
+
+	return nil
+}
+
+func (c *Collector) processHostFolder(ref types.ManagedObjectReference, parentID string, parentPath string, snap *inventorySnapshot) error {
+	folder, ok := snap.folders[ref]
+	if !ok {
+		return fmt.Errorf("host folder %s missing from inventory snapshot", ref.Value)
+	}
+
+	path := parentPath + "/" + folder.Name
+	for _, child := range folder.ChildEntity {
+		switch child.Type {
+		case "Folder":
+			c.processComputeFolder(child, parentID, path, snap)
+		case "ClusterComputeResource":
+			c.processCluster(child, parentID, path, snap)
+		case "ComputeResource":
+			c.processComputeResource(child, parentID, path, snap)
+		case "HostSystem":
+			c.processStandaloneHost(child, parentID, path, snap)
+		}
+	}
+	return nil
+}
+
+func (c *Collector) processComputeFolder(ref types.ManagedObjectReference, parentID string, parentPath string, snap *inventorySnapshot) {
+	folder, ok := snap.folders[ref]
+	if !ok {
+		return
+	}
+
+	folderID := fmt.Sprintf("folder:%s:%s", c.Config.Host, getID(folder.Reference()))
+	c.GraphBuilder.EnsureNode([]string{"Folder"}, folderID, map[string]interface{}{
+		"name": folder.Name,
+		"moid": getID(folder.Reference()),
+	})
+	c.GraphBuilder.AddEdge("CONTAINS", parentID, folderID, nil)
+
+	path := parentPath + "/" + folder.Name
+	for _, child := range folder.ChildEntity {
+		switch child.Type {
+		case "Folder":
+			c.processComputeFolder(child, folderID, path, snap)
+		case "ClusterComputeResource":
+			c.processCluster(child, folderID, path, snap)
+		case "ComputeResource":
+			c.processComputeResource(child, folderID, path, snap)
+		}
+	}
+}
+
+func (c *Collector) processCluster(ref types.ManagedObjectReference, parentID string, parentPath string, snap *inventorySnapshot) {
+	cluster, ok := snap.clusters[ref]
+	if !ok {
+		c.Logger.Error("cluster missing from inventory snapshot", "moid", ref.Value)
+		return
+	}
+
+	path := parentPath + "/" + cluster.Name
+	if !c.clusterFilter.Allows(path) {
+		c.Logger.Debug("skipping cluster excluded by inventory path filter", "path", path)
+		return
+	}
+
+	clusterID := fmt.Sprintf("cluster:%s:%s", c.Config.Host, getID(cluster.Reference()))
+	props := map[string]interface{}{
+		"name": cluster.Name,
+		"moid": getID(cluster.Reference()),
+	}
+
+	if cluster.Summary != nil {
+		s := cluster.Summary.GetComputeResourceSummary()
+		props["totalCpu"] = s.TotalCpu
+		props["totalMemory"] = s.TotalMemory
+		props["numHosts"] = s.NumHosts
+		props["numCpuCores"] = s.NumCpuCores
+		props["numCpuThreads"] = s.NumCpuThreads
+		props["effectiveCpu"] = s.EffectiveCpu
+		props["effectiveMemory"] = s.EffectiveMemory
+	}
+
+	c.GraphBuilder.EnsureNode([]string{"Cluster"}, clusterID, props)
+	c.GraphBuilder.AddEdge("CONTAINS", parentID, clusterID, nil)
+
+	for _, hostRef := range cluster.Host {
+		c.processHost(hostRef, clusterID, path, snap)
+	}
+
+	for _, dsRef := range cluster.Datastore {
+		c.processDatastore(dsRef, "", dcRoot(path)+"/datastore", snap)
+	}
+
+	if cluster.ResourcePool != nil {
+		c.processResourcePool(*cluster.ResourcePool, clusterID, snap)
+	}
+}
+
+func (c *Collector) processComputeResource(ref types.ManagedObjectReference, parentID string, parentPath string, snap *inventorySnapshot) {
+	cr, ok := snap.computeResources[ref]
+	if !ok {
+		return
+	}
+	for _, hostRef := range cr.Host {
+		c.processStandaloneHost(hostRef, parentID, parentPath, snap)
+	}
+}
+
+func (c *Collector) processStandaloneHost(ref types.ManagedObjectReference, parentID string, parentPath string, snap *inventorySnapshot) {
+	c.processHostCommon(ref, parentID, parentPath, true, snap)
+}
+
+func (c *Collector) processHost(ref types.ManagedObjectReference, parentID string, parentPath string, snap *inventorySnapshot) {
+	c.processHostCommon(ref, parentID, parentPath, false, snap)
+}
+
+func (c *Collector) processHostCommon(ref types.ManagedObjectReference, parentID string, parentPath string, isStandalone bool, snap *inventorySnapshot) {
+	host, ok := snap.hosts[ref]
+	if !ok {
+		c.Logger.Error("host missing from inventory snapshot", "moid", ref.Value)
+		return
+	}
+
+	path := parentPath + "/" + host.Name
+	if !c.hostFilter.Allows(path) {
+		c.Logger.Debug("skipping host excluded by inventory path filter", "path", path)
+		return
+	}
+
+	hostID := fmt.Sprintf("esxi_host:%s:%s", c.Config.Host, getID(host.Reference()))
+	props := map[string]interface{}{
+		"name": host.Name,
+		"moid": getID(host.Reference()),
+	}
+	if isStandalone {
+		props["isStandalone"] = true
+	}
+
+	summary := host.Summary
+	if summary.Hardware != nil {
+		hw := summary.Hardware
+		props["vendor"] = hw.Vendor
+		props["model"] = hw.Model
+		props["cpuModel"] = hw.CpuModel
+		props["numCpuCores"] = fmt.Sprintf("%d", hw.NumCpuCores)
+		props["numCpuThreads"] = fmt.Sprintf("%d", hw.NumCpuThreads)
+		props["cpuMhz"] = hw.CpuMhz
+		props["memorySize"] = fmt.Sprintf("%d", hw.MemorySize)
+	}
+	if summary.Config.Product != nil {
+		props["version"] = summary.Config.Product.Version
+		props["build"] = summary.Config.Product.Build
+	}
+	if summary.Runtime != nil {
+		props["connectionState"] = string(summary.Runtime.ConnectionState)
+		props["powerState"] = string(summary.Runtime.PowerState)
+		props["inMaintenanceMode"] = summary.Runtime.InMaintenanceMode
+	}
+
+	c.GraphBuilder.EnsureNode([]string{"ESXiHost"}, hostID, props)
+	c.GraphBuilder.AddEdge("CONTAINS", parentID, hostID, nil)
+
+	for _, vmRef := range host.Vm {
+		c.processVM(vmRef, hostID, path, "HOSTS", snap)
+	}
+
+	for _, dsRef := range host.Datastore {
+		c.processDatastore(dsRef, "", dcRoot(path)+"/datastore", snap)
+	}
+
+	for _, netRef := range host.Network {
+		c.processNetwork(netRef, "", snap)
+	}
+}
+
+func (c *Collector) processVMFolder(ref types.ManagedObjectReference, parentID string, parentPath string, snap *inventorySnapshot) error {
+	folder, ok := snap.folders[ref]
+	if !ok {
+		return fmt.Errorf("VM folder %s missing from inventory snapshot", ref.Value)
+	}
+
+	folderID := fmt.Sprintf("folder:%s:%s", c.Config.Host, getID(folder.Reference()))
+	c.GraphBuilder.EnsureNode([]string{"Folder"}, folderID, map[string]interface{}{
+		"name": folder.Name,
+		"moid": getID(folder.Reference()),
+	})
+	c.GraphBuilder.AddEdge("CONTAINS", parentID, folderID, nil)
+
+	path := parentPath + "/" + folder.Name
+	for _, child := range folder.ChildEntity {
+		switch child.Type {
+		case "Folder":
+			c.processVMFolder(child, folderID, path, snap)
+		case "VirtualMachine":
+			c.processVM(child, folderID, path, "CONTAINS", snap)
+		}
+	}
+	return nil
+}
+
+// processVM creates (or links to) the graph node for a single VM. edgeKind
+// is the relationship drawn from parentID to the VM: "CONTAINS" when
+// parentID is the VM's folder, "HOSTS" when it's the ESXi host running it.
+//
+// A VM is reachable both through its VM-folder path and through its host's
+// Vm list; the folder pass runs first (see processDatacenter) and is the
+// one that evaluates vmFilter, since the folder path is the VM's canonical
+// inventory path. The host-list pass, recognized via vmCreated, only adds
+// its own edgeKind edge -- and only if the folder pass actually created the
+// node, so a VM excluded by vmFilter doesn't gain a dangling HOSTS edge.
+func (c *Collector) processVM(ref types.ManagedObjectReference, parentID string, parentPath string, edgeKind string, snap *inventorySnapshot) {
+	vm, ok := snap.vms[ref]
+	if !ok {
+		return
+	}
+
+	vmID := fmt.Sprintf("vm:%s:%s", c.Config.Host, getID(vm.Reference()))
+
+	if created, visited := c.vmCreated[ref]; visited {
+		if created {
+			c.GraphBuilder.AddEdge(edgeKind, parentID, vmID, nil)
+		}
+		return
+	}
+
+	path := parentPath + "/" + vm.Name
+	if !c.vmFilter.Allows(path) {
+		c.Logger.Debug("skipping VM excluded by inventory path filter", "path", path)
+		c.vmCreated[ref] = false
+		return
+	}
+	c.vmCreated[ref] = true
+
+	props := map[string]interface{}{
+		"name": vm.Name,
+		"moid": getID(vm.Reference()),
+	}
+
+	if vm.Config != nil {
+		props["guestFullName"] = vm.Config.GuestFullName
+		props["guestId"] = vm.Config.GuestId
+		props["version"] = vm.Config.Version
+		props["uuid"] = vm.Config.Uuid
+		props["isTemplate"] = vm.Config.Template
+		if vm.Config.Hardware.NumCPU > 0 {
+			props["numCPU"] = vm.Config.Hardware.NumCPU
+			props["numCoresPerSocket"] = vm.Config.Hardware.NumCoresPerSocket
+			props["memoryMB"] = vm.Config.Hardware.MemoryMB
+		}
+	}
+
+	if vm.Runtime.PowerState != "" {
+		props["powerState"] = string(vm.Runtime.PowerState)
+		props["connectionState"] = string(vm.Runtime.ConnectionState)
+		if vm.Runtime.BootTime != nil {
+			props["bootTime"] = vm.Runtime.BootTime.String()
+		} else {
+			props["bootTime"] = "None"
+		}
+	}
+
+	if vm.Guest != nil {
+		props["toolsStatus"] = string(vm.Guest.ToolsStatus)
+		props["toolsVersion"] = vm.Guest.ToolsVersion
+		props["hostName"] = vm.Guest.HostName
+
+		ipSet := make(map[string]bool)
+
+		if vm.Guest.IpAddress != "" {
+			ipSet[vm.Guest.IpAddress] = true
+		}
+
+		for _, net := range vm.Guest.Net {
+			if net.IpAddress != nil {
+				for _, ip := range net.IpAddress {
+					ipSet[ip] = true
+				}
+			}
+		}
+
+		if len(ipSet) > 0 {
+			var ips []string
+			for ip := range ipSet {
+				ips = append(ips, ip)
+			}
+			sort.Strings(ips)
+			props["ipAddresses"] = ips
+		}
+	}
+
+	if vm.Summary.Storage != nil {
+		storage := vm.Summary.Storage
+		committed := storage.Committed
+		uncommitted := storage.Uncommitted
+		props["storageCommitted"] = bytesToHuman(float64(committed))
+		props["storageUncommitted"] = bytesToHuman(float64(uncommitted))
+		props["storageTotalUsed"] = bytesToHuman(float64(committed + uncommitted))
+	}
+
+	c.GraphBuilder.EnsureNode([]string{"VM"}, vmID, props)
+	c.GraphBuilder.AddEdge(edgeKind, parentID, vmID, nil)
+
+	c.processVMDevices(vm, vmID, snap)
+
+	for _, dsRef := range vm.Datastore {
+		dsID := fmt.Sprintf("datastore:%s:%s", c.Config.Host, getID(dsRef))
+		c.processDatastore(dsRef, "", dcRoot(path)+"/datastore", snap)
+		// processDatastore may have skipped creating the node if it's
+		// excluded by datastoreFilter -- only link to it if it exists, or
+		// BloodHound would receive an edge to a node that was never sent.
+		if c.GraphBuilder.HasNode(dsID) {
+			c.GraphBuilder.AddEdge("USES_DATASTORE", vmID, dsID, nil)
+		}
+	}
+
+	for _, netRef := range vm.Network {
+		netID := fmt.Sprintf("network:%s:%s", c.Config.Host, getID(netRef))
+		c.processNetwork(netRef, "", snap)
+		c.GraphBuilder.AddEdge("USES_NETWORK", vmID, netID, nil)
+	}
+}
+
+func (c *Collector) processDatastore(ref types.ManagedObjectReference, parentID string, parentPath string, snap *inventorySnapshot) {
+	ds, ok := snap.datastores[ref]
+	if !ok {
+		return
+	}
+
+	path := parentPath + "/" + ds.Name
+	if !c.datastoreFilter.Allows(path) {
+		c.Logger.Debug("skipping datastore excluded by inventory path filter", "path", path)
+		return
+	}
+
+	dsID := fmt.Sprintf("datastore:%s:%s", c.Config.Host, getID(ds.Reference()))
+	props := map[string]interface{}{
+		"name": ds.Name,
+		"moid": getID(ds.Reference()),
+	}
+
+	props["type"] = ds.Summary.Type
+	props["capacity"] = fmt.Sprintf("%d", ds.Summary.Capacity)
+	props["freeSpace"] = fmt.Sprintf("%d", ds.Summary.FreeSpace)
+	props["accessible"] = ds.Summary.Accessible
+	props["url"] = ds.Summary.Url
+
+	c.GraphBuilder.EnsureNode([]string{"Datastore"}, dsID, props)
+}
+
+func (c *Collector) processNetwork(ref types.ManagedObjectReference, parentID string, snap *inventorySnapshot) {
+	net := snap.networks[ref]
+
+	isDV := ref.Type == "DistributedVirtualPortgroup"
+
+	kind := "Network"
+	if isDV {
+		kind = "DVPortgroup"
+	}
+
+	netID := fmt.Sprintf("network:%s:%s", c.Config.Host, getID(ref))
+	props := map[string]interface{}{
+		"name": net.Name,
+		"moid": getID(ref),
+		"type": ref.Type,
+		"kind": kind,
+	}
+
+	c.GraphBuilder.EnsureNode([]string{kind}, netID, props)
+}
+
+func (c *Collector) processResourcePool(ref types.ManagedObjectReference, parentID string, snap *inventorySnapshot) {
+	rp := snap.resourcePools[ref]
+
+	rpID := fmt.Sprintf("resource_pool:%s:%s", c.Config.Host, getID(ref))
+	c.GraphBuilder.EnsureNode([]string{"ResourcePool"}, rpID, map[string]interface{}{
+		"name": rp.Name,
+		"moid": getID(ref),
+	})
+
+	for _, child := range rp.ResourcePool {
+		c.processResourcePool(child, rpID, snap)
+	}
+}
+
+// bytesToHuman converts bytes to a human readable string.
+func bytesToHuman(bytesVal float64) string {
+	if bytesVal == 0 {
+		return "0 B"
+	}
+	units := []string{"B", "KB", "MB", "GB", "TB"}
+	for _, unit := range units {
+		if bytesVal < 1024.0 {
+			return fmt.Sprintf("%.1f %s", bytesVal, unit)
+		}
+		bytesVal /= 1024.0
+	}
+	return fmt.Sprintf("%.1f PB", bytesVal)
+}
@@ -0,0 +1,189 @@
+package collector
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/vmware/govmomi/vapi/rest"
+	"github.com/vmware/govmomi/vapi/tags"
+	"github.com/vmware/govmomi/view"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// CollectMetadata enriches the nodes CollectInfrastructure already created
+// with vSphere custom attributes and, unless disabled, vSphere tags.
+func (c *Collector) CollectMetadata() error {
+	if err := c.collectCustomAttributes(); err != nil {
+		c.Logger.Warn("failed to collect custom attributes", "error", err)
+	}
+
+	if c.Config.DisableRESTTagging {
+		c.Logger.Info("REST tagging disabled, skipping tag/category collection")
+		return nil
+	}
+
+	if err := c.collectTags(); err != nil {
+		c.Logger.Warn("failed to collect tags via vAPI", "error", err)
+	}
+
+	return nil
+}
+
+// objectNodeID returns the graph node ID for ref using the same host:moid
+// convention CollectInfrastructure uses when it first creates the node, or
+// false if ref's type isn't one CollectInfrastructure models as a node.
+func objectNodeID(host string, ref types.ManagedObjectReference) (string, bool) {
+	switch ref.Type {
+	case "VirtualMachine":
+		return fmt.Sprintf("vm:%s:%s", host, ref.Value), true
+	case "HostSystem":
+		return fmt.Sprintf("esxi_host:%s:%s", host, ref.Value), true
+	case "ClusterComputeResource":
+		return fmt.Sprintf("cluster:%s:%s", host, ref.Value), true
+	case "Datastore":
+		return fmt.Sprintf("datastore:%s:%s", host, ref.Value), true
+	case "Datacenter":
+		return fmt.Sprintf("datacenter:%s:%s", host, ref.Value), true
+	case "Folder":
+		return fmt.Sprintf("folder:%s:%s", host, ref.Value), true
+	case "Network", "DistributedVirtualPortgroup":
+		return fmt.Sprintf("network:%s:%s", host, ref.Value), true
+	case "ResourcePool":
+		return fmt.Sprintf("resource_pool:%s:%s", host, ref.Value), true
+	default:
+		return "", false
+	}
+}
+
+// collectCustomAttributes bulk-fetches customValue and availableField for
+// every VM/host/datastore/cluster and merges a customAttributes map, keyed
+// by field name rather than vCenter's internal numeric key, onto each
+// object's existing node.
+func (c *Collector) collectCustomAttributes() error {
+	m := view.NewManager(c.Client.Client)
+	kinds := []string{"VirtualMachine", "HostSystem", "Datastore", "ClusterComputeResource"}
+	v, err := m.CreateContainerView(c.Context, c.Client.ServiceContent.RootFolder, kinds, true)
+	if err != nil {
+		return err
+	}
+	defer v.Destroy(c.Context)
+
+	var entities []mo.ManagedEntity
+	if err := v.Retrieve(c.Context, kinds, []string{"customValue", "availableField"}, &entities); err != nil {
+		return fmt.Errorf("failed to bulk-retrieve custom attributes: %w", err)
+	}
+
+	merged := 0
+	for _, e := range entities {
+		nodeID, ok := objectNodeID(c.Config.Host, e.Reference())
+		if !ok || len(e.CustomValue) == 0 {
+			continue
+		}
+
+		fieldNames := make(map[int32]string, len(e.AvailableField))
+		for _, f := range e.AvailableField {
+			fieldNames[f.Key] = f.Name
+		}
+
+		attrs := make(map[string]string, len(e.CustomValue))
+		for _, base := range e.CustomValue {
+			sv, ok := base.(*types.CustomFieldStringValue)
+			if !ok || sv.Value == "" {
+				continue
+			}
+			if name, ok := fieldNames[sv.Key]; ok {
+				attrs[name] = sv.Value
+			}
+		}
+		if len(attrs) == 0 {
+			continue
+		}
+
+		// CollectInfrastructure may not have created nodeID at all, if e was
+		// excluded by one of the inventory path filters. Don't let a bulk,
+		// filter-blind retrieve like this one re-add it as a kind-less
+		// orphan that bypasses the filter.
+		if !c.GraphBuilder.HasNode(nodeID) {
+			continue
+		}
+
+		c.GraphBuilder.EnsureNode(nil, nodeID, map[string]interface{}{"customAttributes": attrs})
+		merged++
+	}
+
+	c.Logger.Info("merged custom attributes", "objects", merged)
+	return nil
+}
+
+// collectTags opens a vAPI REST session through the same Config used for
+// the SOAP session and wires vSphere tags/categories into the graph as
+// their own nodes, with TAGGED_WITH edges back to the objects they're
+// attached to.
+func (c *Collector) collectTags() error {
+	restClient := rest.NewClient(c.Client.Client)
+	if err := restClient.Login(c.Context, url.UserPassword(c.Config.User, c.Config.Password)); err != nil {
+		return fmt.Errorf("vAPI login failed: %w", err)
+	}
+	defer restClient.Logout(c.Context)
+
+	mgr := tags.NewManager(restClient)
+
+	categories, err := mgr.GetCategories(c.Context)
+	if err != nil {
+		return fmt.Errorf("failed to list tag categories: %w", err)
+	}
+	for _, cat := range categories {
+		catID := fmt.Sprintf("tagcategory:%s:%s", c.Config.Host, cat.ID)
+		c.GraphBuilder.EnsureNode([]string{"TagCategory"}, catID, map[string]interface{}{
+			"name":        cat.Name,
+			"description": cat.Description,
+			"cardinality": cat.Cardinality,
+		})
+	}
+
+	tagList, err := mgr.GetTags(c.Context)
+	if err != nil {
+		return fmt.Errorf("failed to list tags: %w", err)
+	}
+	if len(tagList) == 0 {
+		c.Logger.Info("no tags found")
+		return nil
+	}
+
+	tagIDs := make([]string, len(tagList))
+	for i, t := range tagList {
+		tagID := fmt.Sprintf("tag:%s:%s", c.Config.Host, t.ID)
+		tagIDs[i] = t.ID
+
+		c.GraphBuilder.EnsureNode([]string{"Tag"}, tagID, map[string]interface{}{
+			"name":        t.Name,
+			"description": t.Description,
+		})
+		if t.CategoryID != "" {
+			catID := fmt.Sprintf("tagcategory:%s:%s", c.Config.Host, t.CategoryID)
+			c.GraphBuilder.AddEdge("IN_CATEGORY", tagID, catID, nil)
+		}
+	}
+
+	attached, err := mgr.GetAttachedObjectsOnTags(c.Context, tagIDs)
+	if err != nil {
+		return fmt.Errorf("failed to list objects attached to tags: %w", err)
+	}
+
+	tagged := 0
+	for _, assoc := range attached {
+		tagID := fmt.Sprintf("tag:%s:%s", c.Config.Host, assoc.TagID)
+		for _, objRef := range assoc.ObjectIDs {
+			nodeID, ok := objectNodeID(c.Config.Host, objRef.Reference())
+			if !ok {
+				continue
+			}
+			c.GraphBuilder.AddEdge("TAGGED_WITH", nodeID, tagID, nil)
+			tagged++
+		}
+	}
+
+	c.Logger.Info("collected tags via vAPI", "categories", len(categories), "tags", len(tagList), "attachments", tagged)
+	return nil
+}
@@ -0,0 +1,204 @@
+package collector
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/vmware/govmomi/vim25/methods"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// CollectPermissions collects roles, privileges, and the permission
+// assignments that link principals to inventory objects.
+func (c *Collector) CollectPermissions() error {
+	authManagerRef := c.Client.ServiceContent.AuthorizationManager
+	if authManagerRef == nil {
+		return fmt.Errorf("no authorization manager found")
+	}
+
+	var authManager mo.AuthorizationManager
+	err := c.Client.RetrieveOne(c.Context, *authManagerRef, []string{"roleList", "privilegeList"}, &authManager)
+	if err != nil {
+		return err
+	}
+
+	privMap := make(map[string]types.AuthorizationPrivilege)
+	for _, p := range authManager.PrivilegeList {
+		privMap[p.PrivId] = p
+		privID := fmt.Sprintf("privilege:%s:%s", c.Config.Host, p.PrivId)
+		c.GraphBuilder.EnsureNode([]string{"Privilege"}, privID, map[string]interface{}{
+			"privId": p.PrivId,
+			"name":   p.Name,
+			"group":  p.PrivGroupName,
+		})
+	}
+
+	rolesByID := make(map[int32]types.AuthorizationRole)
+	for _, role := range authManager.RoleList {
+		rolesByID[role.RoleId] = role
+		roleID := fmt.Sprintf("role:%s:%d", c.Config.Host, role.RoleId)
+
+		privGroups := make(map[string]bool)
+		for _, pid := range role.Privilege {
+			if p, ok := privMap[pid]; ok {
+				privGroups[p.PrivGroupName] = true
+				pNodeID := fmt.Sprintf("privilege:%s:%s", c.Config.Host, pid)
+				c.GraphBuilder.AddEdge("HAS_PRIVILEGE", roleID, pNodeID, nil)
+			}
+		}
+
+		pgList := make([]string, 0, len(privGroups))
+		for k := range privGroups {
+			pgList = append(pgList, k)
+		}
+
+		c.GraphBuilder.EnsureNode([]string{"Role"}, roleID, map[string]interface{}{
+			"roleId":          role.RoleId,
+			"name":            role.Name,
+			"privilegeCount":  len(role.Privilege),
+			"privilegeGroups": pgList,
+		})
+	}
+	c.Roles = rolesByID
+	c.Privileges = privMap
+
+	perms, err := c.retrieveAllPermissions(*authManagerRef)
+	if err != nil {
+		c.Logger.Error("failed to retrieve permissions", "error", err)
+	}
+	c.Permissions = perms
+
+	for _, perm := range perms {
+		c.processPermission(perm, rolesByID, privMap)
+	}
+
+	return nil
+}
+
+func (c *Collector) retrieveAllPermissions(am types.ManagedObjectReference) ([]types.Permission, error) {
+	req := types.RetrieveAllPermissions{This: am}
+	res, err := methods.RetrieveAllPermissions(c.Context, c.Client.Client, &req)
+	if err != nil {
+		return nil, err
+	}
+	return res.Returnval, nil
+}
+
+func (c *Collector) processPermission(perm types.Permission, roles map[int32]types.AuthorizationRole, privMap map[string]types.AuthorizationPrivilege) {
+	principal := perm.Principal
+	isGroup := perm.Group
+
+	domain, username := c.parsePrincipal(principal)
+
+	var principalID string
+	var kinds []string
+	if isGroup {
+		principalID = fmt.Sprintf("group:%s:%s", c.Config.Host, principal)
+		kinds = []string{"Group"}
+	} else {
+		principalID = fmt.Sprintf("user:%s:%s", c.Config.Host, principal)
+		kinds = []string{"User"}
+	}
+
+	c.GraphBuilder.EnsureNode(kinds, principalID, map[string]interface{}{
+		"name":     principal,
+		"isGroup":  isGroup,
+		"domain":   domain,
+		"username": username,
+	})
+
+	roleID := perm.RoleId
+	roleName := ""
+
+	var privIds []string
+	var privNames []string
+	var privGroups []string
+	privCount := 0
+
+	if r, ok := roles[roleID]; ok {
+		roleName = r.Name
+		privIds = r.Privilege
+		privCount = len(r.Privilege)
+
+		groupSet := make(map[string]bool)
+		for _, pid := range r.Privilege {
+			if p, found := privMap[pid]; found {
+				privNames = append(privNames, p.Name)
+				groupSet[p.PrivGroupName] = true
+			} else {
+				privNames = append(privNames, pid)
+			}
+		}
+		for g := range groupSet {
+			privGroups = append(privGroups, g)
+		}
+		sort.Strings(privGroups)
+	}
+
+	if isNoAccess(roleName) {
+		return
+	}
+
+	entityRef := perm.Entity
+	if entityRef == nil {
+		return
+	}
+
+	entityKind, entityID := c.getEntityKindAndID(*entityRef)
+
+	nodeProps := map[string]interface{}{}
+	if !c.GraphBuilder.HasNode(entityID) {
+		nodeProps["moid"] = getID(*entityRef)
+		nodeProps["name"] = getID(*entityRef)
+	} else {
+		nodeProps["moid"] = getID(*entityRef)
+	}
+
+	c.GraphBuilder.EnsureNode([]string{entityKind}, entityID, nodeProps)
+
+	props := map[string]interface{}{
+		"roleId":          roleID,
+		"roleName":        roleName,
+		"propagate":       perm.Propagate,
+		"privilegeIds":    privIds,
+		"privilegeNames":  privNames,
+		"privilegeGroups": privGroups,
+		"privilegeCount":  privCount,
+	}
+
+	c.GraphBuilder.AddEdge("HAS_PERMISSION", principalID, entityID, props)
+}
+
+func isNoAccess(roleName string) bool {
+	l := strings.ToLower(roleName)
+	return l == "no access" || l == "noaccess" || l == "no-access"
+}
+
+func (c *Collector) getEntityKindAndID(ref types.ManagedObjectReference) (string, string) {
+	moid := getID(ref)
+
+	switch ref.Type {
+	case "Datacenter":
+		return "Datacenter", fmt.Sprintf("datacenter:%s:%s", c.Config.Host, moid)
+	case "ClusterComputeResource":
+		return "Cluster", fmt.Sprintf("cluster:%s:%s", c.Config.Host, moid)
+	case "HostSystem":
+		return "ESXiHost", fmt.Sprintf("esxi_host:%s:%s", c.Config.Host, moid)
+	case "ComputeResource":
+		var cr mo.ComputeResource
+		err := c.Client.RetrieveOne(c.Context, ref, []string{"host"}, &cr)
+		if err == nil && len(cr.Host) > 0 {
+			hostMoid := cr.Host[0].Value
+			return "ESXiHost", fmt.Sprintf("esxi_host:%s:%s", c.Config.Host, hostMoid)
+		}
+		return "ESXiHost", fmt.Sprintf("esxi_host:%s:%s", c.Config.Host, moid)
+	case "VirtualMachine":
+		return "VM", fmt.Sprintf("vm:%s:%s", c.Config.Host, moid)
+	case "Folder":
+		return "Folder", fmt.Sprintf("folder:%s:%s", c.Config.Host, moid)
+	}
+	cleanType := ref.Type
+	return cleanType, fmt.Sprintf("%s:%s:%s", strings.ToLower(cleanType), c.Config.Host, moid)
+}
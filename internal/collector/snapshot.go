@@ -0,0 +1,166 @@
+package collector
+
+import (
+	"fmt"
+
+	"github.com/vmware/govmomi/view"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// inventorySnapshot holds every managed object CollectInfrastructure needs to
+// walk the tree, indexed by reference. It is fetched once per run with a
+// handful of RetrieveProperties calls instead of one RetrieveOne per object,
+// so CollectInfrastructure's traversal itself is pure in-memory lookups.
+type inventorySnapshot struct {
+	folders          map[types.ManagedObjectReference]mo.Folder
+	datacenters      map[types.ManagedObjectReference]mo.Datacenter
+	clusters         map[types.ManagedObjectReference]mo.ClusterComputeResource
+	computeResources map[types.ManagedObjectReference]mo.ComputeResource
+	hosts            map[types.ManagedObjectReference]mo.HostSystem
+	vms              map[types.ManagedObjectReference]mo.VirtualMachine
+	datastores       map[types.ManagedObjectReference]mo.Datastore
+	networks         map[types.ManagedObjectReference]mo.Network
+	resourcePools    map[types.ManagedObjectReference]mo.ResourcePool
+
+	// datastoreIDByName maps a datastore's name to its graph node ID, so a
+	// VirtualDisk's "[datastoreName] path/file.vmdk" backing filename (which
+	// only carries the name) can be resolved to an ATTACHED_TO_DATASTORE edge
+	// target.
+	datastoreIDByName map[string]string
+}
+
+// fetchInventorySnapshot builds one recursive ContainerView over the whole
+// inventory and issues a single RetrieveProperties call per managed object
+// type, requesting a fixed list of fields rather than whole objects, plus one
+// RetrieveOne for the root folder, which the view does not contain. This is what
+// turns a multi-thousand-object collection from one round trip per object
+// into a handful of round trips total.
+func (c *Collector) fetchInventorySnapshot() (*inventorySnapshot, error) {
+	m := view.NewManager(c.Client.Client)
+
+	kinds := []string{
+		"Folder", "Datacenter", "ClusterComputeResource", "ComputeResource",
+		"HostSystem", "VirtualMachine", "Datastore", "Network",
+		"DistributedVirtualPortgroup", "ResourcePool",
+	}
+	v, err := m.CreateContainerView(c.Context, c.Client.ServiceContent.RootFolder, kinds, true)
+	if err != nil {
+		return nil, err
+	}
+	defer v.Destroy(c.Context)
+
+	snap := &inventorySnapshot{
+		folders:          make(map[types.ManagedObjectReference]mo.Folder),
+		datacenters:      make(map[types.ManagedObjectReference]mo.Datacenter),
+		clusters:         make(map[types.ManagedObjectReference]mo.ClusterComputeResource),
+		computeResources: make(map[types.ManagedObjectReference]mo.ComputeResource),
+		hosts:            make(map[types.ManagedObjectReference]mo.HostSystem),
+		vms:              make(map[types.ManagedObjectReference]mo.VirtualMachine),
+		datastores:       make(map[types.ManagedObjectReference]mo.Datastore),
+		networks:         make(map[types.ManagedObjectReference]mo.Network),
+		resourcePools:    make(map[types.ManagedObjectReference]mo.ResourcePool),
+
+		datastoreIDByName: make(map[string]string),
+	}
+
+	var folders []mo.Folder
+	if err := v.Retrieve(c.Context, []string{"Folder"}, []string{"name", "childEntity"}, &folders); err != nil {
+		return nil, fmt.Errorf("failed to bulk-retrieve folders: %w", err)
+	}
+	for _, f := range folders {
+		snap.folders[f.Reference()] = f
+	}
+
+	// A ContainerView holds the objects inside its container, not the
+	// container itself, so the root folder is fetched on its own.
+	rootRef := c.Client.ServiceContent.RootFolder
+	if _, ok := snap.folders[rootRef]; !ok {
+		var root mo.Folder
+		if err := c.Client.RetrieveOne(c.Context, rootRef, []string{"name", "childEntity"}, &root); err != nil {
+			return nil, fmt.Errorf("failed to retrieve root folder: %w", err)
+		}
+		snap.folders[rootRef] = root
+	}
+
+	var datacenters []mo.Datacenter
+	if err := v.Retrieve(c.Context, []string{"Datacenter"}, []string{"name", "hostFolder", "vmFolder", "datastore", "network"}, &datacenters); err != nil {
+		return nil, fmt.Errorf("failed to bulk-retrieve datacenters: %w", err)
+	}
+	for _, dc := range datacenters {
+		snap.datacenters[dc.Reference()] = dc
+	}
+
+	var clusters []mo.ClusterComputeResource
+	if err := v.Retrieve(c.Context, []string{"ClusterComputeResource"}, []string{"name", "host", "datastore", "network", "resourcePool", "summary", "configuration"}, &clusters); err != nil {
+		return nil, fmt.Errorf("failed to bulk-retrieve clusters: %w", err)
+	}
+	for _, cl := range clusters {
+		snap.clusters[cl.Reference()] = cl
+	}
+
+	var computeResources []mo.ComputeResource
+	if err := v.Retrieve(c.Context, []string{"ComputeResource"}, []string{"host"}, &computeResources); err != nil {
+		return nil, fmt.Errorf("failed to bulk-retrieve compute resources: %w", err)
+	}
+	for _, cr := range computeResources {
+		snap.computeResources[cr.Reference()] = cr
+	}
+
+	var hosts []mo.HostSystem
+	if err := v.Retrieve(c.Context, []string{"HostSystem"}, []string{"name", "summary", "vm", "datastore", "network"}, &hosts); err != nil {
+		return nil, fmt.Errorf("failed to bulk-retrieve hosts: %w", err)
+	}
+	for _, h := range hosts {
+		snap.hosts[h.Reference()] = h
+	}
+
+	var vms []mo.VirtualMachine
+	if err := v.Retrieve(c.Context, []string{"VirtualMachine"}, []string{"name", "config", "guest", "runtime", "summary", "datastore", "network"}, &vms); err != nil {
+		return nil, fmt.Errorf("failed to bulk-retrieve VMs: %w", err)
+	}
+	for _, vm := range vms {
+		snap.vms[vm.Reference()] = vm
+	}
+
+	var datastores []mo.Datastore
+	if err := v.Retrieve(c.Context, []string{"Datastore"}, []string{"name", "summary", "info"}, &datastores); err != nil {
+		return nil, fmt.Errorf("failed to bulk-retrieve datastores: %w", err)
+	}
+	for _, ds := range datastores {
+		snap.datastores[ds.Reference()] = ds
+		snap.datastoreIDByName[ds.Name] = fmt.Sprintf("datastore:%s:%s", c.Config.Host, getID(ds.Reference()))
+	}
+
+	var networks []mo.Network
+	if err := v.Retrieve(c.Context, []string{"Network"}, []string{"name"}, &networks); err != nil {
+		return nil, fmt.Errorf("failed to bulk-retrieve networks: %w", err)
+	}
+	for _, n := range networks {
+		snap.networks[n.Reference()] = n
+	}
+
+	// DistributedVirtualPortgroup is a distinct managed object type from
+	// Network, but shares the name field processNetwork reads, so it's
+	// fetched into the same mo.Network-keyed map. Its summary is a
+	// DVPortgroupSummary rather than mo.Network's BaseNetworkSummary, so
+	// it must not be requested here -- decoding it into a mo.Network would
+	// fail and abort this whole retrieve.
+	var portgroups []mo.Network
+	if err := v.Retrieve(c.Context, []string{"DistributedVirtualPortgroup"}, []string{"name"}, &portgroups); err != nil {
+		return nil, fmt.Errorf("failed to bulk-retrieve distributed portgroups: %w", err)
+	}
+	for _, n := range portgroups {
+		snap.networks[n.Reference()] = n
+	}
+
+	var resourcePools []mo.ResourcePool
+	if err := v.Retrieve(c.Context, []string{"ResourcePool"}, []string{"name", "resourcePool", "vApp", "vm"}, &resourcePools); err != nil {
+		return nil, fmt.Errorf("failed to bulk-retrieve resource pools: %w", err)
+	}
+	for _, rp := range resourcePools {
+		snap.resourcePools[rp.Reference()] = rp
+	}
+
+	return snap, nil
+}
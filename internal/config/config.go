@@ -1,5 +1,7 @@
 package config
 
+import "vcenterhoundgo/internal/credentials"
+
 // Config holds the runtime configuration
 type Config struct {
 	Host        string
@@ -11,4 +13,32 @@ type Config struct {
 	BHURL       string
 	BHKeyID     string
 	BHKeySecret string
+
+	// Inventory-path include/exclude filters, e.g. "/DC1/vm/prod/**" or
+	// "/DC1/host/cluster-*/**". An Include list (if non-empty) is an allow
+	// list: a node must match at least one of its patterns to be collected.
+	// Exclude patterns always apply, even with no Include list set. See
+	// collector.pathFilter for the glob syntax.
+	VMInclude        []string
+	VMExclude        []string
+	HostInclude      []string
+	HostExclude      []string
+	ClusterInclude   []string
+	ClusterExclude   []string
+	DatastoreInclude []string
+	DatastoreExclude []string
+
+	// DisableRESTTagging skips the vAPI tags/categories REST session
+	// CollectMetadata otherwise opens, for environments where that endpoint
+	// is blocked. Custom attribute collection is plain SOAP PropertyCollector
+	// and is unaffected.
+	DisableRESTTagging bool
+
+	// CollectGuest enables CollectGuestData, which lists in-guest processes
+	// (and, on Windows, local accounts) via GuestOperationsManager. It
+	// requires GuestCredentials to be set.
+	CollectGuest bool
+	// GuestCredentials resolves the guest OS login to use per-VM. Never
+	// logged -- see credentials.GuestCredentialMap.
+	GuestCredentials *credentials.GuestCredentialMap
 }
@@ -0,0 +1,45 @@
+package credentials
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// EnvProvider reads credentials from VCH_USER_<HOST> and VCH_PASS_<HOST>
+// environment variables, with host upper-cased and every character outside
+// [A-Z0-9] replaced with "_" so hostnames like "vc-01.corp.local" turn into
+// valid variable names.
+type EnvProvider struct{}
+
+// NewEnvProvider creates an EnvProvider. It takes no configuration -- the
+// variable names are derived entirely from the host passed to Fetch.
+func NewEnvProvider() *EnvProvider {
+	return &EnvProvider{}
+}
+
+func (p *EnvProvider) Fetch(host string) (string, string, error) {
+	key := envKey(host)
+
+	user, ok := os.LookupEnv("VCH_USER_" + key)
+	if !ok {
+		return "", "", fmt.Errorf("environment variable VCH_USER_%s not set", key)
+	}
+	pass, ok := os.LookupEnv("VCH_PASS_" + key)
+	if !ok {
+		return "", "", fmt.Errorf("environment variable VCH_PASS_%s not set", key)
+	}
+	return user, pass, nil
+}
+
+func envKey(host string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(host) {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune('_')
+		}
+	}
+	return b.String()
+}
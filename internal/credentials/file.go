@@ -0,0 +1,68 @@
+package credentials
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileProvider resolves credentials from a per-host secrets file (YAML or
+// JSON, picked by file extension) shaped like:
+//
+//	hosts:
+//	  vc-01.corp.local:
+//	    user: svc-vcenterhoundgo
+//	    pass: hunter2
+type FileProvider struct {
+	hosts map[string]fileEntry
+}
+
+type fileEntry struct {
+	User string `json:"user" yaml:"user"`
+	Pass string `json:"pass" yaml:"pass"`
+}
+
+type fileDocument struct {
+	Hosts map[string]fileEntry `json:"hosts" yaml:"hosts"`
+}
+
+// NewFileProvider loads and parses the secrets file at path, refusing to
+// load one that's readable by group or other -- a secrets file leaking via
+// loose permissions is cheaper to catch here than after the fact.
+func NewFileProvider(path string) (*FileProvider, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat credentials file %s: %w", path, err)
+	}
+	if info.Mode().Perm()&0077 != 0 {
+		return nil, fmt.Errorf("credentials file %s must not be readable by group or other (mode %04o)", path, info.Mode().Perm())
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read credentials file %s: %w", path, err)
+	}
+
+	var doc fileDocument
+	if strings.HasSuffix(path, ".yaml") || strings.HasSuffix(path, ".yml") {
+		err = yaml.Unmarshal(data, &doc)
+	} else {
+		err = json.Unmarshal(data, &doc)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse credentials file %s: %w", path, err)
+	}
+
+	return &FileProvider{hosts: doc.Hosts}, nil
+}
+
+func (p *FileProvider) Fetch(host string) (string, string, error) {
+	entry, ok := p.hosts[host]
+	if !ok {
+		return "", "", fmt.Errorf("no credentials entry for host %s in credentials file", host)
+	}
+	return entry.User, entry.Pass, nil
+}
@@ -0,0 +1,89 @@
+package credentials
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// GuestCredentialMap resolves the in-guest credential to use for a VM, for
+// CollectGuestData. Unlike Provider, which resolves one vCenter login per
+// host, a single vCenter can hold VMs running many different guest OSes and
+// owned by many different teams, so entries are matched per-VM instead of
+// per-host.
+type GuestCredentialMap struct {
+	entries []guestCredentialEntry
+}
+
+type guestCredentialEntry struct {
+	// Match is a glob against the VM's display name, e.g. "prod-win-*". Left
+	// empty, it matches every VM.
+	Match string `json:"match" yaml:"match"`
+	// OSFamily restricts the entry to VMs whose guest.guestFamily equals this
+	// value (e.g. "windowsGuest", "linuxGuest"), case-insensitively. Left
+	// empty, it matches every OS family.
+	OSFamily string `json:"osFamily" yaml:"osFamily"`
+	User     string `json:"user" yaml:"user"`
+	Pass     string `json:"pass" yaml:"pass"`
+}
+
+type guestCredentialDocument struct {
+	Credentials []guestCredentialEntry `json:"credentials" yaml:"credentials"`
+}
+
+// LoadGuestCredentialMap loads a YAML mapping file shaped like:
+//
+//	credentials:
+//	  - match: "prod-win-*"
+//	    osFamily: windowsGuest
+//	    user: svc-guestops
+//	    pass: hunter2
+//	  - osFamily: linuxGuest
+//	    user: guestops
+//	    pass: hunter3
+//
+// Entries are tried in file order and the first match wins, so a
+// catch-all entry (no match/osFamily) belongs last. Same permission
+// requirement as NewFileProvider -- this file holds plaintext guest
+// passwords.
+func LoadGuestCredentialMap(path string) (*GuestCredentialMap, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat guest credentials file %s: %w", path, err)
+	}
+	if info.Mode().Perm()&0077 != 0 {
+		return nil, fmt.Errorf("guest credentials file %s must not be readable by group or other (mode %04o)", path, info.Mode().Perm())
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read guest credentials file %s: %w", path, err)
+	}
+
+	var doc guestCredentialDocument
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse guest credentials file %s: %w", path, err)
+	}
+
+	return &GuestCredentialMap{entries: doc.Credentials}, nil
+}
+
+// Resolve returns the first entry whose match glob (if set) matches vmName
+// and whose osFamily (if set) matches osFamily, case-insensitively.
+func (m *GuestCredentialMap) Resolve(vmName, osFamily string) (user, pass string, ok bool) {
+	for _, e := range m.entries {
+		if e.Match != "" {
+			if matched, _ := filepath.Match(e.Match, vmName); !matched {
+				continue
+			}
+		}
+		if e.OSFamily != "" && !strings.EqualFold(e.OSFamily, osFamily) {
+			continue
+		}
+		return e.User, e.Pass, true
+	}
+	return "", "", false
+}
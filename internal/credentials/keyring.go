@@ -0,0 +1,35 @@
+package credentials
+
+import (
+	"fmt"
+
+	"github.com/zalando/go-keyring"
+)
+
+// KeyringProvider resolves credentials from the host OS's native credential
+// store (macOS Keychain, Windows Credential Manager, Secret Service on
+// Linux) via zalando/go-keyring. service scopes the lookup so multiple
+// tools can share a keyring without colliding; it defaults to
+// "vcenterhoundgo" if empty.
+type KeyringProvider struct {
+	service string
+}
+
+func NewKeyringProvider(service string) (*KeyringProvider, error) {
+	if service == "" {
+		service = "vcenterhoundgo"
+	}
+	return &KeyringProvider{service: service}, nil
+}
+
+func (p *KeyringProvider) Fetch(host string) (string, string, error) {
+	user, err := keyring.Get(p.service, host+":user")
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read keyring entry %s/%s:user: %w", p.service, host, err)
+	}
+	pass, err := keyring.Get(p.service, host+":pass")
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read keyring entry %s/%s:pass: %w", p.service, host, err)
+	}
+	return user, pass, nil
+}
@@ -0,0 +1,44 @@
+// Package credentials resolves per-host vCenter credentials from a
+// pluggable backend instead of a plaintext -p flag, so passwords don't end
+// up in shell history or process listings when this tool is run in CI or
+// against production vCenters.
+package credentials
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Provider resolves the username and password to use for a given vCenter
+// host. Implementations may hit a remote secret store on every call, so
+// callers should fetch once per host, not once per use.
+type Provider interface {
+	Fetch(host string) (user, pass string, err error)
+}
+
+// New builds a Provider from a "-credentials" flag value of the form
+// "<scheme>://<spec>":
+//
+//	env://
+//	file:///etc/vcenterhoundgo/secrets.yaml
+//	vault://secret/data/vcenter/{host}
+//	keyring://vcenterhoundgo
+func New(spec string) (Provider, error) {
+	scheme, rest, ok := strings.Cut(spec, "://")
+	if !ok {
+		return nil, fmt.Errorf("invalid -credentials value %q: expected <scheme>://<spec>", spec)
+	}
+
+	switch scheme {
+	case "env":
+		return NewEnvProvider(), nil
+	case "file":
+		return NewFileProvider(rest)
+	case "vault":
+		return NewVaultProvider(rest)
+	case "keyring":
+		return NewKeyringProvider(rest)
+	default:
+		return nil, fmt.Errorf("unknown -credentials scheme %q", scheme)
+	}
+}
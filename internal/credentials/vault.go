@@ -0,0 +1,123 @@
+package credentials
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// VaultProvider resolves credentials from a HashiCorp Vault KV v2 secret,
+// read fresh on every Fetch call so a rotated secret takes effect on the
+// next host without restarting the process. pathTemplate may contain a
+// "{host}" placeholder, e.g. "secret/data/vcenter/{host}".
+type VaultProvider struct {
+	Addr         string
+	Token        string
+	PathTemplate string
+	Client       *http.Client
+}
+
+// NewVaultProvider builds a VaultProvider for the given KV v2 path template.
+// The Vault address is read from VAULT_ADDR. The token comes from
+// VAULT_TOKEN, or, if that's unset, from an AppRole login using
+// VAULT_ROLE_ID/VAULT_SECRET_ID.
+func NewVaultProvider(pathTemplate string) (*VaultProvider, error) {
+	addr := os.Getenv("VAULT_ADDR")
+	if addr == "" {
+		return nil, fmt.Errorf("VAULT_ADDR must be set to use the vault credentials provider")
+	}
+	addr = strings.TrimRight(addr, "/")
+
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	token := os.Getenv("VAULT_TOKEN")
+	if token == "" {
+		var err error
+		token, err = approleLogin(client, addr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to authenticate to vault: %w", err)
+		}
+	}
+
+	return &VaultProvider{
+		Addr:         addr,
+		Token:        token,
+		PathTemplate: pathTemplate,
+		Client:       client,
+	}, nil
+}
+
+func approleLogin(client *http.Client, addr string) (string, error) {
+	roleID := os.Getenv("VAULT_ROLE_ID")
+	secretID := os.Getenv("VAULT_SECRET_ID")
+	if roleID == "" || secretID == "" {
+		return "", fmt.Errorf("VAULT_TOKEN not set and VAULT_ROLE_ID/VAULT_SECRET_ID not set for AppRole login")
+	}
+
+	body, err := json.Marshal(map[string]string{"role_id": roleID, "secret_id": secretID})
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := client.Post(addr+"/v1/auth/approle/login", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return "", fmt.Errorf("AppRole login returned status %d", resp.StatusCode)
+	}
+
+	var loginResp struct {
+		Auth struct {
+			ClientToken string `json:"client_token"`
+		} `json:"auth"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&loginResp); err != nil {
+		return "", err
+	}
+	return loginResp.Auth.ClientToken, nil
+}
+
+func (p *VaultProvider) Fetch(host string) (string, string, error) {
+	path := strings.ReplaceAll(p.PathTemplate, "{host}", host)
+
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s/v1/%s", p.Addr, path), nil)
+	if err != nil {
+		return "", "", err
+	}
+	req.Header.Set("X-Vault-Token", p.Token)
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read vault secret at %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return "", "", fmt.Errorf("vault returned status %d reading %s", resp.StatusCode, path)
+	}
+
+	var secretResp struct {
+		Data struct {
+			Data struct {
+				User string `json:"user"`
+				Pass string `json:"pass"`
+			} `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&secretResp); err != nil {
+		return "", "", fmt.Errorf("failed to decode vault response from %s: %w", path, err)
+	}
+
+	if secretResp.Data.Data.User == "" || secretResp.Data.Data.Pass == "" {
+		return "", "", fmt.Errorf("vault secret at %s is missing user/pass fields", path)
+	}
+
+	return secretResp.Data.Data.User, secretResp.Data.Data.Pass, nil
+}
@@ -0,0 +1,147 @@
+// Package directory resolves an Active Directory domain's FQDN to its
+// NetBIOS name via a real LDAP lookup, replacing the "first label of the
+// FQDN" heuristic used elsewhere in this repo (which breaks whenever a
+// domain's NetBIOS name differs from its leftmost DNS label).
+package directory
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// Resolver looks up NetBIOS names over LDAP and caches the result on disk so
+// repeated runs don't re-query AD for domains that have already been solved.
+type Resolver struct {
+	// User/Pass authenticate the LDAP bind. Both empty means an anonymous bind.
+	User string
+	Pass string
+
+	cachePath string
+	mu        sync.Mutex
+	cache     map[string]string // FQDN -> NetBIOS
+}
+
+// NewResolver creates a Resolver backed by a JSON cache file. The cache is
+// loaded eagerly; a missing or unreadable cache file just starts empty.
+func NewResolver(cachePath, user, pass string) *Resolver {
+	r := &Resolver{
+		User:      user,
+		Pass:      pass,
+		cachePath: cachePath,
+		cache:     make(map[string]string),
+	}
+	r.load()
+	return r
+}
+
+func (r *Resolver) load() {
+	if r.cachePath == "" {
+		return
+	}
+	data, err := os.ReadFile(r.cachePath)
+	if err != nil {
+		return
+	}
+	_ = json.Unmarshal(data, &r.cache)
+}
+
+func (r *Resolver) save() {
+	if r.cachePath == "" {
+		return
+	}
+	data, err := json.MarshalIndent(r.cache, "", "  ")
+	if err != nil {
+		return
+	}
+	_ = os.WriteFile(r.cachePath, data, 0600)
+}
+
+// NetBIOS returns the NetBIOS name for the given AD domain FQDN, consulting
+// the on-disk cache first, then LDAP. The caller should fall back to the
+// "first label of the FQDN" heuristic if err is non-nil.
+func (r *Resolver) NetBIOS(fqdn string) (string, error) {
+	fqdn = strings.ToUpper(fqdn)
+
+	r.mu.Lock()
+	if netbios, ok := r.cache[fqdn]; ok {
+		r.mu.Unlock()
+		return netbios, nil
+	}
+	r.mu.Unlock()
+
+	netbios, err := r.lookupNetBIOS(fqdn)
+	if err != nil {
+		return "", err
+	}
+
+	r.mu.Lock()
+	r.cache[fqdn] = netbios
+	r.save()
+	r.mu.Unlock()
+
+	return netbios, nil
+}
+
+// lookupNetBIOS binds to the domain's LDAP service and reads nETBIOSName off
+// the cross-reference object for this domain under
+// CN=Partitions,CN=Configuration,DC=...
+func (r *Resolver) lookupNetBIOS(fqdn string) (string, error) {
+	conn, err := ldap.DialURL(fmt.Sprintf("ldaps://%s:636", fqdn), ldap.DialWithTLSConfig(&tls.Config{InsecureSkipVerify: true}))
+	if err != nil {
+		conn, err = ldap.DialURL(fmt.Sprintf("ldap://%s:389", fqdn))
+		if err != nil {
+			return "", fmt.Errorf("failed to connect to LDAP on %s: %w", fqdn, err)
+		}
+	}
+	defer conn.Close()
+
+	if r.User != "" {
+		if err := conn.Bind(r.User, r.Pass); err != nil {
+			return "", fmt.Errorf("LDAP bind failed for %s: %w", fqdn, err)
+		}
+	} else {
+		if err := conn.UnauthenticatedBind(""); err != nil {
+			return "", fmt.Errorf("anonymous LDAP bind failed for %s: %w", fqdn, err)
+		}
+	}
+
+	baseDN := dnFromFQDN(fqdn)
+	configDN := fmt.Sprintf("CN=Partitions,CN=Configuration,%s", baseDN)
+
+	searchReq := ldap.NewSearchRequest(
+		configDN,
+		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
+		fmt.Sprintf("(&(objectClass=crossRef)(dnsRoot=%s))", ldap.EscapeFilter(fqdn)),
+		[]string{"nETBIOSName"},
+		nil,
+	)
+
+	res, err := conn.Search(searchReq)
+	if err != nil {
+		return "", fmt.Errorf("LDAP search failed for %s: %w", fqdn, err)
+	}
+
+	for _, entry := range res.Entries {
+		if netbios := entry.GetAttributeValue("nETBIOSName"); netbios != "" {
+			return strings.ToUpper(netbios), nil
+		}
+	}
+
+	return "", fmt.Errorf("no nETBIOSName found for %s under %s", fqdn, configDN)
+}
+
+// dnFromFQDN converts "corp.example.com" to "DC=corp,DC=example,DC=com".
+func dnFromFQDN(fqdn string) string {
+	labels := strings.Split(fqdn, ".")
+	parts := make([]string, len(labels))
+	for i, l := range labels {
+		parts[i] = "DC=" + l
+	}
+	return strings.Join(parts, ",")
+}
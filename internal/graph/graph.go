@@ -0,0 +1,297 @@
+// Package graph builds the OpenGraph-shaped node/edge set that gets written
+// to vcenter_graph.json or streamed to BloodHound.
+package graph
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// GraphNode represents a single OpenGraph node.
+type GraphNode struct {
+	Kinds      []string               `json:"kinds"`
+	ID         string                 `json:"id"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// GraphEdge represents a single OpenGraph edge. Start/End are encoded as
+// {"value": ..., "match_by": ...} objects on the wire, matching BloodHound's
+// ingest schema.
+type GraphEdge struct {
+	Kind         string
+	StartID      string
+	StartMatchBy string
+	EndID        string
+	EndMatchBy   string
+	Properties   map[string]interface{}
+}
+
+type graphEndpoint struct {
+	Value   string `json:"value"`
+	MatchBy string `json:"match_by,omitempty"`
+}
+
+type graphEdgeWire struct {
+	Kind       string                 `json:"kind"`
+	Start      graphEndpoint          `json:"start"`
+	End        graphEndpoint          `json:"end"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// MarshalJSON nests Start/End IDs and their match_by mode the way BloodHound expects.
+func (e GraphEdge) MarshalJSON() ([]byte, error) {
+	return json.Marshal(graphEdgeWire{
+		Kind:       e.Kind,
+		Start:      graphEndpoint{Value: e.StartID, MatchBy: e.StartMatchBy},
+		End:        graphEndpoint{Value: e.EndID, MatchBy: e.EndMatchBy},
+		Properties: e.Properties,
+	})
+}
+
+// UnmarshalJSON reads an edge back from the nested start/end wire format.
+func (e *GraphEdge) UnmarshalJSON(data []byte) error {
+	var wire graphEdgeWire
+	if err := json.Unmarshal(data, &wire); err != nil {
+		return err
+	}
+	e.Kind = wire.Kind
+	e.StartID = wire.Start.Value
+	e.StartMatchBy = wire.Start.MatchBy
+	e.EndID = wire.End.Value
+	e.EndMatchBy = wire.End.MatchBy
+	e.Properties = wire.Properties
+	return nil
+}
+
+// GraphData is the node/edge set for one collection run.
+type GraphData struct {
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
+// FinalOutput is the root JSON object written to disk or uploaded.
+type FinalOutput struct {
+	Graph GraphData `json:"graph"`
+}
+
+// Constants for node/edge kind prefixing, matching BloodHound's custom-node convention.
+const (
+	NodePrefix = "vCenter_"
+	EdgePrefix = "vCenter_"
+)
+
+// NodeTypeMap maps internal node kind names to their BloodHound display kind.
+var NodeTypeMap = map[string]string{
+	"vCenter":           "vCenter",
+	"RootFolder":        "RootFolder",
+	"Datacenter":        "Datacenter",
+	"Cluster":           "Cluster",
+	"ESXiHost":          "ESXiHost",
+	"ResourcePool":      "ResourcePool",
+	"vApp":              "vApp",
+	"VM":                "VM",
+	"VMTemplate":        "VMTemplate",
+	"Datastore":         "Datastore",
+	"DatastoreCluster":  "DatastoreCluster",
+	"Network":           "Network",
+	"StandardPortgroup": "StandardPortgroup",
+	"DVSwitch":          "DVSwitch",
+	"DVPortgroup":       "DVPortgroup",
+	"Principal":         "Principal",
+	"User":              "User",
+	"Group":             "Group",
+	"Privilege":         "Privilege",
+	"Role":              "Role",
+	"Folder":            "Folder",
+	"IdentityDomain":    "IdentityDomain",
+}
+
+// EdgeTypeMap maps internal edge kind names to their BloodHound display kind.
+var EdgeTypeMap = map[string]string{
+	"CONTAINS":       "Contains",
+	"HOSTS":          "Hosts",
+	"HAS_PERMISSION": "HasPermission",
+	"MEMBER_OF":      "MemberOf",
+	"USES_DATASTORE": "UsesDatastore",
+	"USES_NETWORK":   "UsesNetwork",
+	"HAS_DATASTORE":  "HasDatastore",
+	"HAS_NETWORK":    "HasNetwork",
+	"MOUNTS":         "Mounts",
+	"HAS_PRIVILEGE":  "HasPrivilege",
+}
+
+// Builder accumulates nodes and edges for a single collection run. It is
+// safe for concurrent use: callers running collection against multiple
+// vCenter hosts in parallel can share one Builder and merge their results
+// automatically instead of reconciling separate builders afterward.
+type Builder struct {
+	mu        sync.RWMutex
+	NodesByID map[string]*GraphNode
+	Edges     []GraphEdge
+	EdgeKeys  map[string]bool
+}
+
+// NewBuilder initializes a new Builder.
+func NewBuilder() *Builder {
+	return &Builder{
+		NodesByID: make(map[string]*GraphNode),
+		Edges:     []GraphEdge{},
+		EdgeKeys:  make(map[string]bool),
+	}
+}
+
+// FormatNodeKind formats a node kind with its BloodHound prefix.
+func FormatNodeKind(kind string) string {
+	mapped, ok := NodeTypeMap[kind]
+	if !ok {
+		mapped = kind
+	}
+	cleaned := strings.NewReplacer(".", "_", "-", "_", " ", "_").Replace(mapped)
+	return NodePrefix + cleaned
+}
+
+// FormatEdgeKind formats an edge kind with its BloodHound prefix.
+func FormatEdgeKind(kind string) string {
+	mapped, ok := EdgeTypeMap[kind]
+	if !ok {
+		mapped = kind
+	}
+	return EdgePrefix + mapped
+}
+
+func propsToKey(props map[string]interface{}) string {
+	if len(props) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(props))
+	for k := range props {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = fmt.Sprintf("%v:%v", k, props[k])
+	}
+	return strings.Join(parts, "|")
+}
+
+// HasNode reports whether a node with the given ID has already been added.
+func (b *Builder) HasNode(nodeID string) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	_, exists := b.NodesByID[nodeID]
+	return exists
+}
+
+// EnsureNode ensures a node exists in the graph, merging kinds and
+// overwriting properties if it already does.
+func (b *Builder) EnsureNode(kinds []string, nodeID string, properties map[string]interface{}) *GraphNode {
+	formattedKinds := make([]string, len(kinds))
+	for i, k := range kinds {
+		formattedKinds[i] = FormatNodeKind(k)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if existing, exists := b.NodesByID[nodeID]; exists {
+		existingKinds := make(map[string]bool, len(existing.Kinds))
+		for _, k := range existing.Kinds {
+			existingKinds[k] = true
+		}
+		for _, k := range formattedKinds {
+			if !existingKinds[k] {
+				existing.Kinds = append(existing.Kinds, k)
+				existingKinds[k] = true
+			}
+		}
+		for k, v := range properties {
+			existing.Properties[k] = v
+		}
+		return existing
+	}
+
+	node := &GraphNode{Kinds: formattedKinds, ID: nodeID, Properties: properties}
+	b.NodesByID[nodeID] = node
+	return node
+}
+
+// AddRawNode inserts a node whose kinds are already formatted, without
+// reformatting or prefixing them, as when reloading a previously exported
+// graph.
+func (b *Builder) AddRawNode(kinds []string, nodeID string, properties map[string]interface{}) *GraphNode {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if existing, exists := b.NodesByID[nodeID]; exists {
+		return existing
+	}
+	node := &GraphNode{Kinds: kinds, ID: nodeID, Properties: properties}
+	b.NodesByID[nodeID] = node
+	return node
+}
+
+// AddEdge adds an edge between two nodes matched by ID, deduplicating on
+// (kind, start, end, properties).
+func (b *Builder) AddEdge(kind string, startID string, endID string, properties map[string]interface{}) {
+	b.addEdge(FormatEdgeKind(kind), startID, "id", endID, "id", properties)
+}
+
+// AddRawEdgeWithMatch adds an edge with an already-formatted kind and an
+// explicit match_by mode on each endpoint (e.g. "id" or "name"), without
+// reformatting the kind, as when reloading a previously exported graph.
+func (b *Builder) AddRawEdgeWithMatch(kind, startID, startMatchBy, endID, endMatchBy string, properties map[string]interface{}) {
+	b.addEdge(kind, startID, startMatchBy, endID, endMatchBy, properties)
+}
+
+func (b *Builder) addEdge(kind, startID, startMatchBy, endID, endMatchBy string, properties map[string]interface{}) {
+	if properties == nil {
+		properties = make(map[string]interface{})
+	}
+
+	edgeKey := fmt.Sprintf("%s:%s:%s:%s", kind, startID, endID, propsToKey(properties))
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.EdgeKeys[edgeKey] {
+		return
+	}
+	b.EdgeKeys[edgeKey] = true
+
+	b.Edges = append(b.Edges, GraphEdge{
+		Kind:         kind,
+		StartID:      startID,
+		StartMatchBy: startMatchBy,
+		EndID:        endID,
+		EndMatchBy:   endMatchBy,
+		Properties:   properties,
+	})
+}
+
+// Counts returns the current total node and edge count, for coarse
+// before/after deltas (e.g. phase-level telemetry) rather than precise
+// per-caller attribution.
+func (b *Builder) Counts() (nodes, edges int) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.NodesByID), len(b.Edges)
+}
+
+// Export returns the accumulated nodes and edges as GraphData.
+func (b *Builder) Export() GraphData {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	nodes := make([]GraphNode, 0, len(b.NodesByID))
+	for _, node := range b.NodesByID {
+		nodes = append(nodes, *node)
+	}
+	edges := make([]GraphEdge, len(b.Edges))
+	copy(edges, b.Edges)
+	return GraphData{Nodes: nodes, Edges: edges}
+}
@@ -0,0 +1,42 @@
+package graph
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// TestBuilderConcurrentAccess hammers EnsureNode and AddEdge from many
+// goroutines to prove no duplicate edges slip past EdgeKeys dedup and no
+// data race corrupts NodesByID/Edges.
+func TestBuilderConcurrentAccess(t *testing.T) {
+	b := NewBuilder()
+
+	const goroutines = 50
+	const iterations = 100
+
+	var wg sync.WaitGroup
+	wg.Add(goroutines)
+
+	for g := 0; g < goroutines; g++ {
+		go func() {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				nodeID := fmt.Sprintf("vm:host:%d", i)
+				b.EnsureNode([]string{"VM"}, nodeID, map[string]interface{}{"name": nodeID})
+				// Every goroutine adds the same edge; only one copy should survive dedup.
+				b.AddEdge("CONTAINS", "vcenter:host", nodeID, nil)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	data := b.Export()
+	if len(data.Nodes) != iterations {
+		t.Fatalf("expected %d distinct nodes, got %d", iterations, len(data.Nodes))
+	}
+	if len(data.Edges) != iterations {
+		t.Fatalf("expected %d deduplicated edges, got %d", iterations, len(data.Edges))
+	}
+}
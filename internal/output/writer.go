@@ -3,21 +3,36 @@ package output
 import (
 	"encoding/json"
 	"os"
+
+	"vcenterhoundgo/internal/bloodhound"
 	"vcenterhoundgo/internal/graph"
 )
 
+// Sink is anywhere a collected graph can be delivered once a run finishes:
+// a JSON file on disk, or directly into a BloodHound instance.
+type Sink interface {
+	Write(data graph.GraphData) error
+}
+
 // Output structure for JSON file
 type Output struct {
 	Graph graph.GraphData `json:"graph"`
 }
 
-// WriteToFile writes the graph data to a JSON file
-func WriteToFile(data graph.GraphData, filename string) error {
-	out := Output{
-		Graph: data,
-	}
+// FileSink writes a graph to a JSON file, in the same {"graph": {...}}
+// shape BloodHound's file-upload job API also accepts. This is the default
+// sink every collection run uses.
+type FileSink struct {
+	Path string
+}
+
+// NewFileSink creates a FileSink writing to path.
+func NewFileSink(path string) *FileSink {
+	return &FileSink{Path: path}
+}
 
-	file, err := os.Create(filename)
+func (s *FileSink) Write(data graph.GraphData) error {
+	file, err := os.Create(s.Path)
 	if err != nil {
 		return err
 	}
@@ -25,5 +40,33 @@ func WriteToFile(data graph.GraphData, filename string) error {
 
 	enc := json.NewEncoder(file)
 	enc.SetIndent("", "  ")
-	return enc.Encode(out)
+	return enc.Encode(Output{Graph: data})
+}
+
+// defaultChunkSize is the combined node+edge count BloodHoundSink sends per
+// ingest request.
+const defaultChunkSize = 10000
+
+// BloodHoundSink streams a graph directly into a BloodHound instance
+// through Client.PostGraph, skipping the intermediate JSON file.
+type BloodHoundSink struct {
+	Client *bloodhound.Client
+
+	// ChunkSize caps the combined node+edge count sent per ingest request.
+	// Defaults to defaultChunkSize if zero.
+	ChunkSize int
+}
+
+// NewBloodHoundSink creates a BloodHoundSink using client, with the default
+// chunk size.
+func NewBloodHoundSink(client *bloodhound.Client) *BloodHoundSink {
+	return &BloodHoundSink{Client: client, ChunkSize: defaultChunkSize}
+}
+
+func (s *BloodHoundSink) Write(data graph.GraphData) error {
+	chunkSize := s.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = defaultChunkSize
+	}
+	return s.Client.PostGraph(data, chunkSize)
 }
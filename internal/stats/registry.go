@@ -0,0 +1,186 @@
+package stats
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Report is the full -stats-out JSON document: per-host phase timings plus
+// totals summed across every host.
+type Report struct {
+	Hosts  []HostStats          `json:"hosts"`
+	Totals map[string]PhaseStat `json:"totals"`
+}
+
+// kindHost scopes a node/edge kind count to the host it was collected from.
+type kindHost struct {
+	kind string
+	host string
+}
+
+// Registry aggregates HostStats and per-kind node/edge counts from every
+// Collector in a run, and renders them as a -time table, a -stats-out JSON
+// report, or a Prometheus /metrics endpoint. A single Registry is meant to
+// live for the whole daemon process; Reset clears it at the start of each
+// run so /metrics always reflects the most recent one.
+type Registry struct {
+	mu         sync.Mutex
+	hosts      []HostStats
+	nodeCounts map[kindHost]int
+	edgeCounts map[kindHost]int
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		nodeCounts: make(map[kindHost]int),
+		edgeCounts: make(map[kindHost]int),
+	}
+}
+
+// Reset discards every recorded host and counter, so a long-lived Registry
+// can be reused across daemon-mode ticks.
+func (reg *Registry) Reset() {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	reg.hosts = nil
+	reg.nodeCounts = make(map[kindHost]int)
+	reg.edgeCounts = make(map[kindHost]int)
+}
+
+// AddHost records one Collector's phase timings.
+func (reg *Registry) AddHost(hs HostStats) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	reg.hosts = append(reg.hosts, hs)
+}
+
+// AddNodeCounts records per-kind node counts collected from host.
+func (reg *Registry) AddNodeCounts(host string, counts map[string]int) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	for kind, n := range counts {
+		reg.nodeCounts[kindHost{kind: kind, host: host}] += n
+	}
+}
+
+// AddEdgeCounts records per-kind edge counts collected from host.
+func (reg *Registry) AddEdgeCounts(host string, counts map[string]int) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	for kind, n := range counts {
+		reg.edgeCounts[kindHost{kind: kind, host: host}] += n
+	}
+}
+
+// Report builds the aggregate -stats-out document.
+func (reg *Registry) Report() Report {
+	reg.mu.Lock()
+	hosts := make([]HostStats, len(reg.hosts))
+	copy(hosts, reg.hosts)
+	reg.mu.Unlock()
+
+	totals := make(map[string]PhaseStat)
+	for _, hs := range hosts {
+		for _, p := range hs.Phases {
+			t := totals[p.Name]
+			t.Name = p.Name
+			t.Duration += p.Duration
+			t.Objects += p.Objects
+			t.APICalls += p.APICalls
+			totals[p.Name] = t
+		}
+	}
+
+	return Report{Hosts: hosts, Totals: totals}
+}
+
+// WriteJSON writes the aggregate report as indented JSON to path.
+func (reg *Registry) WriteJSON(path string) error {
+	data, err := json.MarshalIndent(reg.Report(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal stats report: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write stats report to %s: %w", path, err)
+	}
+	return nil
+}
+
+// WriteTimingTable writes a compact per-host/per-phase timing table to w, in
+// the spirit of gclog's timing dump.
+func (reg *Registry) WriteTimingTable(w io.Writer) {
+	report := reg.Report()
+
+	fmt.Fprintf(w, "%-24s %-28s %10s %10s %10s\n", "HOST", "PHASE", "DURATION", "OBJECTS", "API CALLS")
+	for _, hs := range report.Hosts {
+		for _, p := range hs.Phases {
+			fmt.Fprintf(w, "%-24s %-28s %10s %10d %10d\n", hs.Host, p.Name, p.Duration.Round(time.Millisecond), p.Objects, p.APICalls)
+		}
+	}
+
+	var names []string
+	for name := range report.Totals {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(w, "---")
+	for _, name := range names {
+		t := report.Totals[name]
+		fmt.Fprintf(w, "%-24s %-28s %10s %10d %10d\n", "TOTAL", t.Name, t.Duration.Round(time.Millisecond), t.Objects, t.APICalls)
+	}
+}
+
+// ServeMetrics renders node/edge/phase/API-call counters in Prometheus text
+// exposition format for -metrics-listen.
+func (reg *Registry) ServeMetrics(w http.ResponseWriter, r *http.Request) {
+	reg.mu.Lock()
+	nodeCounts := make(map[kindHost]int, len(reg.nodeCounts))
+	for k, v := range reg.nodeCounts {
+		nodeCounts[k] = v
+	}
+	edgeCounts := make(map[kindHost]int, len(reg.edgeCounts))
+	for k, v := range reg.edgeCounts {
+		edgeCounts[k] = v
+	}
+	hosts := make([]HostStats, len(reg.hosts))
+	copy(hosts, reg.hosts)
+	reg.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	fmt.Fprintf(w, "# HELP vch_nodes_total Graph nodes collected, by kind and host.\n")
+	fmt.Fprintf(w, "# TYPE vch_nodes_total counter\n")
+	for k, v := range nodeCounts {
+		fmt.Fprintf(w, "vch_nodes_total{kind=%q,host=%q} %d\n", k.kind, k.host, v)
+	}
+
+	fmt.Fprintf(w, "# HELP vch_edges_total Graph edges collected, by kind and host.\n")
+	fmt.Fprintf(w, "# TYPE vch_edges_total counter\n")
+	for k, v := range edgeCounts {
+		fmt.Fprintf(w, "vch_edges_total{kind=%q,host=%q} %d\n", k.kind, k.host, v)
+	}
+
+	fmt.Fprintf(w, "# HELP vch_phase_duration_seconds Collection phase duration, by phase and host.\n")
+	fmt.Fprintf(w, "# TYPE vch_phase_duration_seconds gauge\n")
+	for _, hs := range hosts {
+		for _, p := range hs.Phases {
+			fmt.Fprintf(w, "vch_phase_duration_seconds{phase=%q,host=%q} %f\n", p.Name, hs.Host, p.Duration.Seconds())
+		}
+	}
+
+	fmt.Fprintf(w, "# HELP vch_api_calls_total vCenter API calls made, by phase and host.\n")
+	fmt.Fprintf(w, "# TYPE vch_api_calls_total counter\n")
+	for _, hs := range hosts {
+		for _, p := range hs.Phases {
+			fmt.Fprintf(w, "vch_api_calls_total{endpoint=%q,host=%q} %d\n", p.Name, hs.Host, p.APICalls)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+// Package stats times each collection phase and tallies the objects and API
+// calls it produced, so runs can be inspected with -time, -stats-out or
+// -metrics-listen instead of guessing which phase is slow from log timing.
+package stats
+
+import (
+	"sync"
+	"time"
+)
+
+// PhaseStat records one phase's timing and volume for a single host.
+type PhaseStat struct {
+	Name     string        `json:"name"`
+	Duration time.Duration `json:"duration"`
+	Objects  int           `json:"objects"`
+	APICalls int           `json:"apiCalls"`
+}
+
+// HostStats collects every PhaseStat recorded for one vCenter host.
+type HostStats struct {
+	Host   string      `json:"host"`
+	Phases []PhaseStat `json:"phases"`
+}
+
+// Recorder accumulates PhaseStats for a single Collector run. Collect runs
+// its phases one after another, but Phase and the func it returns are still
+// mutex-guarded so a Recorder can be read mid-run (e.g. by a future -time
+// progress display) without racing.
+type Recorder struct {
+	host string
+
+	mu     sync.Mutex
+	phases []PhaseStat
+}
+
+// NewRecorder creates a Recorder for the given host.
+func NewRecorder(host string) *Recorder {
+	return &Recorder{host: host}
+}
+
+// Phase starts timing a phase, returning a func the caller invokes once the
+// phase is done with the object and API call counts it produced.
+func (r *Recorder) Phase(name string) func(objects, apiCalls int) {
+	started := time.Now()
+	return func(objects, apiCalls int) {
+		r.mu.Lock()
+		r.phases = append(r.phases, PhaseStat{
+			Name:     name,
+			Duration: time.Since(started),
+			Objects:  objects,
+			APICalls: apiCalls,
+		})
+		r.mu.Unlock()
+	}
+}
+
+// HostStats returns a snapshot of every phase recorded so far.
+func (r *Recorder) HostStats() HostStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	phases := make([]PhaseStat, len(r.phases))
+	copy(phases, r.phases)
+	return HostStats{Host: r.host, Phases: phases}
+}
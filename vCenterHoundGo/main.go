@@ -1,16 +1,21 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
+	"sort"
 	"strings"
+	"sync"
 
 	"vCenterHoundGo/collector"
 	"vCenterHoundGo/graph"
+
+	"golang.org/x/sync/errgroup"
 )
 
 func main() {
@@ -21,6 +26,8 @@ func main() {
 	outPtr := flag.String("o", "vcenter_graph.json", "Output file")
 	verbosePtr := flag.Bool("v", false, "Verbose logging")
 	modePtr := flag.String("mode", "collect", "Execution mode: collect")
+	concurrencyPtr := flag.Int("concurrency", 4, "Maximum number of vCenter hosts to collect from in parallel")
+	failFastPtr := flag.Bool("fail-fast", false, "Abort the remaining pool as soon as one host fails to collect")
 
 	// Long flags
 	flag.StringVar(serverPtr, "server", "", "vCenter server(s)")
@@ -50,74 +57,78 @@ func main() {
 	banner := `
 █ █ █▀▀ █▀▀ █▀█ ▀█▀ █▀▀ █▀▄ █ █ █▀█ █ █ █▀█ █▀▄
 ▀▄▀ █   █▀▀ █ █  █  █▀▀ █▀▄ █▀█ █ █ █ █ █ █ █ █
- ▀  ▀▀▀ ▀▀▀ ▀ ▀  ▀  ▀▀▀ ▀ ▀ ▀ ▀ ▀▀▀ ▀▀▀ ▀ ▀ ▀▀ 
+ ▀  ▀▀▀ ▀▀▀ ▀ ▀  ▀  ▀▀▀ ▀ ▀ ▀ ▀ ▀▀▀ ▀▀▀ ▀ ▀ ▀▀
 vCenterHoundGo - vCenter to BloodHound Graph Converter
 `
 	fmt.Println(banner)
 
 	servers := strings.Split(*serverPtr, ",")
-	var connectors []*collector.Collector
 	mergedGraph := graph.NewGraphBuilder()
 
-	for _, host := range servers {
-		host = strings.TrimSpace(host)
+	// Collect from each host in a bounded worker pool, each against its own
+	// GraphBuilder so collection is isolated and race-free; the per-host
+	// builders are merged into mergedGraph once every worker has finished.
+	eg, ctx := errgroup.WithContext(context.Background())
+	eg.SetLimit(*concurrencyPtr)
+
+	var mu sync.Mutex
+	var builders []*graph.GraphBuilder
+	var succeeded, failed []string
+
+	for _, rawHost := range servers {
+		host := strings.TrimSpace(rawHost)
 		if host == "" {
 			continue
 		}
 
-		cfg := collector.VCenterConfig{
-			Host:     host,
-			User:     *userPtr,
-			Password: *passPtr,
-			Port:     *portPtr,
-		}
+		eg.Go(func() error {
+			if *failFastPtr && ctx.Err() != nil {
+				return ctx.Err()
+			}
 
-		// Each collector builds into its own builder? Or shared?
-		// Python matches merged graph at the end.
-		// Go implementation: simple to have 1 builder or merge them.
-		// If we use shared builder, we must be careful with concurrency if we were parallel,
-		// but here we are sequential.
+			cfg := collector.VCenterConfig{
+				Host:     host,
+				User:     *userPtr,
+				Password: *passPtr,
+				Port:     *portPtr,
+			}
 
-		// Let's use individual collectors to isolate failures, then merge.
-		gb := graph.NewGraphBuilder() // Individual builder
-		col := collector.NewCollector(cfg, gb)
+			gb := graph.NewGraphBuilder()
+			col := collector.NewCollector(cfg, gb)
 
-		log.Printf("Starting collection for %s...", host)
-		if err := col.Collect(); err != nil {
-			log.Printf("Failed to collect from %s: %v", host, err)
-			continue
-		}
-
-		connectors = append(connectors, col)
-
-		// Merge into mergedGraph
-		for _, node := range gb.NodesByID {
-			// Manually merge to avoid double-prefixing Kinds (EnsureNode re-formats Kinds)
-			if existing, exists := mergedGraph.NodesByID[node.ID]; exists {
-				// Merge properties
-				for k, v := range node.Properties {
-					if _, has := existing.Properties[k]; !has {
-						existing.Properties[k] = v
-					}
+			log.Printf("Starting collection for %s...", host)
+			if err := col.Collect(); err != nil {
+				log.Printf("Failed to collect from %s: %v", host, err)
+				mu.Lock()
+				failed = append(failed, host)
+				mu.Unlock()
+				if *failFastPtr {
+					return fmt.Errorf("collection failed for %s: %w", host, err)
 				}
-			} else {
-				// Copy node directly
-				mergedGraph.NodesByID[node.ID] = node
+				return nil
 			}
-		}
-		for _, edge := range gb.Edges {
-			// Convert start/end map to ID string for AddEdge
-			// Remove prefix from kind if present? AddEdge adds it again.
-			// Ideally we shouldn't Format twice.
-			// But GraphBuilder.AddEdge takes raw Kind.
-			// GraphEdge struct stores Formatted Kind.
-			// So merging requires raw Kind? Or we just append Edges directly?
-			// Direct append is safer for exact copy.
-			mergedGraph.Edges = append(mergedGraph.Edges, edge)
-		}
+
+			mu.Lock()
+			succeeded = append(succeeded, host)
+			builders = append(builders, gb)
+			mu.Unlock()
+			return nil
+		})
 	}
 
-	if len(connectors) == 0 {
+	_ = eg.Wait()
+
+	log.Println("--- Collection Summary ---")
+	sort.Strings(succeeded)
+	sort.Strings(failed)
+	log.Printf("Succeeded (%d): %s", len(succeeded), strings.Join(succeeded, ", "))
+	log.Printf("Failed (%d): %s", len(failed), strings.Join(failed, ", "))
+
+	for _, gb := range builders {
+		mergeGraph(mergedGraph, gb)
+	}
+
+	if len(succeeded) == 0 {
 		log.Println("No data collected from any vCenter")
 		os.Exit(1)
 	}
@@ -156,6 +167,102 @@ vCenterHoundGo - vCenter to BloodHound Graph Converter
 	log.Println("vCenterHoundGo collection completed successfully")
 }
 
+// mergeGraph merges a per-host GraphBuilder into merged. Nodes are merged by
+// ID, filling in any properties merged doesn't already have. Edges are
+// merged by (kind, start, end): the same principal (e.g.
+// VSPHERE.LOCAL\Administrators) can hold the same role on multiple
+// vCenters, so a naive append would duplicate that edge instead of unioning
+// its privilege properties.
+func mergeGraph(merged *graph.GraphBuilder, gb *graph.GraphBuilder) {
+	for _, node := range gb.NodesByID {
+		if existing, exists := merged.NodesByID[node.ID]; exists {
+			for k, v := range node.Properties {
+				if _, has := existing.Properties[k]; !has {
+					existing.Properties[k] = v
+				}
+			}
+		} else {
+			merged.NodesByID[node.ID] = node
+		}
+	}
+
+	existingByKey := make(map[edgeKey]int, len(merged.Edges))
+	for i, e := range merged.Edges {
+		existingByKey[edgeKeyOf(e)] = i
+	}
+
+	for _, edge := range gb.Edges {
+		key := edgeKeyOf(edge)
+		if idx, ok := existingByKey[key]; ok {
+			unionEdgeProperties(&merged.Edges[idx], edge.Properties)
+			continue
+		}
+		merged.Edges = append(merged.Edges, edge)
+		existingByKey[key] = len(merged.Edges) - 1
+	}
+}
+
+type edgeKey struct {
+	kind  string
+	start string
+	end   string
+}
+
+func edgeKeyOf(e graph.GraphEdge) edgeKey {
+	return edgeKey{kind: e.Kind, start: e.StartID["value"], end: e.EndID["value"]}
+}
+
+// unionListProperties are edge properties that should be merged as
+// deduplicated sets across hosts instead of overwritten -- a role grant can
+// carry a different privilege set per vCenter even when the principal,
+// role name and target entity are the same.
+var unionListProperties = map[string]bool{
+	"privilegeIds":    true,
+	"privilegeNames":  true,
+	"privilegeGroups": true,
+}
+
+func unionEdgeProperties(dst *graph.GraphEdge, src map[string]interface{}) {
+	if dst.Properties == nil {
+		dst.Properties = make(map[string]interface{})
+	}
+	for k, v := range src {
+		if unionListProperties[k] {
+			dst.Properties[k] = unionStringValues(dst.Properties[k], v)
+			continue
+		}
+		dst.Properties[k] = v
+	}
+}
+
+func unionStringValues(existing, incoming interface{}) []string {
+	seen := make(map[string]bool)
+	var out []string
+
+	add := func(v interface{}) {
+		switch vals := v.(type) {
+		case []string:
+			for _, s := range vals {
+				if !seen[s] {
+					seen[s] = true
+					out = append(out, s)
+				}
+			}
+		case []interface{}:
+			for _, item := range vals {
+				if s, ok := item.(string); ok && !seen[s] {
+					seen[s] = true
+					out = append(out, s)
+				}
+			}
+		}
+	}
+
+	add(existing)
+	add(incoming)
+	return out
+}
+
 func sanitizeGraph(output *graph.FinalOutput) {
 	for i := range output.Graph.Nodes {
 		sanitizeProperties(output.Graph.Nodes[i].Properties)
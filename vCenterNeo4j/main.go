@@ -2,12 +2,15 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"encoding/json"
 	"flag"
 	"fmt"
-	"log"
 	"os"
 	"strings"
 
+	"github.com/go-ldap/ldap/v3"
+	"github.com/hashicorp/go-hclog"
 	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
 )
 
@@ -17,6 +20,11 @@ func main() {
 	username := flag.String("u", "neo4j", "Username")
 	password := flag.String("p", "", "Password")
 	sync := flag.Bool("sync", false, "Sync relationships to AD")
+	ldapUser := flag.String("ldap-user", "", "LDAP bind DN/username for NetBIOS resolution (anonymous bind if empty)")
+	ldapPass := flag.String("ldap-pass", "", "LDAP bind password for NetBIOS resolution")
+	ldapCache := flag.String("ldap-cache", "netbios_cache.json", "Path to the on-disk NetBIOS resolution cache")
+	logLevel := flag.String("log-level", "info", "Log level: trace, debug, info, warn, error")
+	logJSON := flag.Bool("log-json", false, "Emit logs as JSON instead of human-readable text")
 
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage of %s:\n", os.Args[0])
@@ -33,6 +41,17 @@ func main() {
 
 	flag.Parse()
 
+	level := hclog.LevelFromString(*logLevel)
+	if level == hclog.NoLevel {
+		level = hclog.Info
+	}
+	logger := hclog.New(&hclog.LoggerOptions{
+		Name:       "vcenter-sync",
+		Level:      level,
+		Output:     os.Stderr,
+		JSONFormat: *logJSON,
+	})
+
 	if *password == "" {
 		fmt.Println("Please provide a password using -p")
 		os.Exit(1)
@@ -47,23 +66,26 @@ func main() {
 		}
 	}
 
-	fmt.Printf("Connecting to %s...\n", target)
+	logger.Info("connecting to Neo4j", "target", target)
 
 	ctx := context.Background()
 	driver, err := neo4j.NewDriverWithContext(target, neo4j.BasicAuth(*username, *password, ""))
 	if err != nil {
-		log.Fatalf("Failed to create driver: %v", err)
+		logger.Error("failed to create driver", "error", err)
+		os.Exit(1)
 	}
 	defer driver.Close(ctx)
 
 	err = driver.VerifyConnectivity(ctx)
 	if err != nil {
-		log.Fatalf("Failed to verify connectivity: %v", err)
+		logger.Error("failed to verify connectivity", "error", err)
+		os.Exit(1)
 	}
-	fmt.Println("Connected to Neo4j")
+	logger.Info("connected to Neo4j")
 
 	if *sync {
-		syncRelationships(ctx, driver)
+		resolver := newNetBIOSResolver(*ldapCache, *ldapUser, *ldapPass, logger.Named("directory"))
+		syncRelationships(ctx, driver, resolver, logger.Named("sync"))
 		return
 	}
 
@@ -77,21 +99,22 @@ func main() {
 		neo4j.ExecuteQueryWithDatabase("neo4j"))
 
 	if err != nil {
-		log.Fatalf("Failed to execute query: %v", err)
+		logger.Error("failed to execute query", "error", err)
+		os.Exit(1)
 	}
 
-	fmt.Printf("Found %d nodes\n", len(result.Records))
+	logger.Info("found nodes", "count", len(result.Records))
 
 	for _, record := range result.Records {
 		val, ok := record.Get("u")
 		if !ok {
-			log.Printf("Node 'u' not found in record")
+			logger.Warn("node 'u' not found in record")
 			continue
 		}
 
 		node, ok := val.(neo4j.Node)
 		if !ok {
-			log.Printf("Value is not a neo4j.Node")
+			logger.Warn("value is not a neo4j.Node")
 			continue
 		}
 
@@ -100,27 +123,27 @@ func main() {
 	}
 }
 
-func syncRelationships(ctx context.Context, driver neo4j.DriverWithContext) {
-	fmt.Println("Starting sync...")
+func syncRelationships(ctx context.Context, driver neo4j.DriverWithContext, resolver *netBIOSResolver, logger hclog.Logger) {
+	logger.Info("starting sync")
 
 	// 1. Get Domain Map (NetBIOS -> FQDN)
-	domainMap := getDomainMap(ctx, driver)
-	fmt.Printf("Domain Map: %v\n", domainMap)
+	domainMap := getDomainMap(ctx, driver, resolver, logger)
+	logger.Debug("resolved domain map", "domains", domainMap)
 
 	// 2. Sync Users
-	syncNodes(ctx, driver, domainMap, "vCenter_User", "SyncsTovCenterUser", "User")
+	syncNodes(ctx, driver, domainMap, "vCenter_User", "SyncsTovCenterUser", "User", logger)
 
 	// 3. Sync Groups
-	syncNodes(ctx, driver, domainMap, "vCenter_Group", "SyncsTovCenterGroup", "Group")
+	syncNodes(ctx, driver, domainMap, "vCenter_Group", "SyncsTovCenterGroup", "Group", logger)
 
-	fmt.Println("Sync completed.")
+	logger.Info("sync completed")
 }
 
-func getDomainMap(ctx context.Context, driver neo4j.DriverWithContext) map[string]string {
+func getDomainMap(ctx context.Context, driver neo4j.DriverWithContext, resolver *netBIOSResolver, logger hclog.Logger) map[string]string {
 	query := "MATCH (d:Domain) RETURN d.name"
 	result, err := neo4j.ExecuteQuery(ctx, driver, query, nil, neo4j.EagerResultTransformer, neo4j.ExecuteQueryWithDatabase("neo4j"))
 	if err != nil {
-		log.Printf("Failed to get domains: %v", err)
+		logger.Error("failed to get domains", "error", err)
 		return nil
 	}
 
@@ -128,30 +151,36 @@ func getDomainMap(ctx context.Context, driver neo4j.DriverWithContext) map[strin
 	for _, record := range result.Records {
 		val, ok := record.Get("d.name")
 		if ok {
-			fqdn := val.(string)
-			// Heuristic: NetBIOS is first part of FQDN
-			parts := strings.Split(fqdn, ".")
-			if len(parts) > 0 {
-				netbios := strings.ToUpper(parts[0])
-				dMap[netbios] = strings.ToUpper(fqdn)
-			}
+			fqdn := strings.ToUpper(val.(string))
+			dMap[resolver.netBIOS(fqdn)] = fqdn
 		}
 	}
 	return dMap
 }
 
-func syncNodes(ctx context.Context, driver neo4j.DriverWithContext, domainMap map[string]string, vLabel, relType, adLabel string) {
-	fmt.Printf("Syncing %s -> %s...\n", vLabel, adLabel)
+// syncBatchSize caps how many rows are sent per UNWIND batch so a single
+// transaction doesn't grow unbounded on very large directories.
+const syncBatchSize = 500
+
+// syncRow is one {adName, vID} pair consumed by the UNWIND batch statement.
+type syncRow struct {
+	AdName string `json:"adName"`
+	VID    int64  `json:"vID"`
+}
+
+func syncNodes(ctx context.Context, driver neo4j.DriverWithContext, domainMap map[string]string, vLabel, relType, adLabel string, logger hclog.Logger) int {
+	logger.Info("syncing vCenter nodes to AD", "vLabel", vLabel, "adLabel", adLabel)
 
-	// Iterate valid vCenter nodes
+	// Fetch all vCenter nodes for this label in one query instead of
+	// interleaving a read with every write.
 	query := fmt.Sprintf("MATCH (v:%s) RETURN v", vLabel)
 	result, err := neo4j.ExecuteQuery(ctx, driver, query, nil, neo4j.EagerResultTransformer, neo4j.ExecuteQueryWithDatabase("neo4j"))
 	if err != nil {
-		log.Printf("Failed to fetch %s: %v", vLabel, err)
-		return
+		logger.Error("failed to fetch nodes", "vLabel", vLabel, "error", err)
+		return 0
 	}
 
-	count := 0
+	rows := make([]syncRow, 0, len(result.Records))
 	for _, record := range result.Records {
 		val, ok := record.Get("v")
 		if !ok {
@@ -167,44 +196,161 @@ func syncNodes(ctx context.Context, driver neo4j.DriverWithContext, domainMap ma
 			continue
 		}
 
-		// Resolve FQDN
 		fqdn, ok := domainMap[strings.ToUpper(domain)]
 		if !ok {
 			// Skip if domain not mapped (e.g. local vsphere.local or unknown)
 			continue
 		}
 
-		targetName := fmt.Sprintf("%s@%s", strings.ToUpper(username), fqdn)
+		rows = append(rows, syncRow{
+			AdName: fmt.Sprintf("%s@%s", strings.ToUpper(username), fqdn),
+			VID:    node.Id,
+		})
+	}
 
-		// Create Relationship
-		// MATCH (ad:ADLabel {name: targetName}), (v) WHERE id(v) = $vid
-		// MERGE (ad)-[:REL]->(v)
+	merged := 0
+	for i := 0; i < len(rows); i += syncBatchSize {
+		end := i + syncBatchSize
+		if end > len(rows) {
+			end = len(rows)
+		}
+		batch := rows[i:end]
 
-		cypher := fmt.Sprintf(`
-			MATCH (ad:%s {name: $adName})
-			MATCH (v:%s) WHERE id(v) = $vID
-			MERGE (ad)-[:%s]->(v)
-			RETURN count(ad)
-		`, adLabel, vLabel, relType)
+		n, err := syncBatch(ctx, driver, batch, vLabel, adLabel, relType)
+		if err != nil {
+			logger.Error("error syncing batch", "vLabel", vLabel, "batchStart", i, "batchEnd", end, "error", err)
+			continue
+		}
+		merged += n
+	}
 
-		params := map[string]any{
-			"adName": targetName,
-			"vID":    node.Id,
+	logger.Info("synced relationships", "relType", relType, "count", merged)
+	return merged
+}
+
+// syncBatch merges one UNWIND batch of {adName, vID} rows inside an explicit
+// write transaction, so a failure partway through rolls back the whole batch
+// rather than leaving it half-applied.
+func syncBatch(ctx context.Context, driver neo4j.DriverWithContext, batch []syncRow, vLabel, adLabel, relType string) (int, error) {
+	session := driver.NewSession(ctx, neo4j.SessionConfig{DatabaseName: "neo4j"})
+	defer session.Close(ctx)
+
+	rows := make([]map[string]any, len(batch))
+	for i, r := range batch {
+		rows[i] = map[string]any{"adName": r.AdName, "vID": r.VID}
+	}
+
+	cypher := fmt.Sprintf(`
+		UNWIND $rows AS row
+		MATCH (ad:%s {name: row.adName})
+		MATCH (v:%s) WHERE id(v) = row.vID
+		MERGE (ad)-[:%s]->(v)
+		RETURN count(ad) AS merged
+	`, adLabel, vLabel, relType)
+
+	merged, err := session.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		res, err := tx.Run(ctx, cypher, map[string]any{"rows": rows})
+		if err != nil {
+			return 0, err
 		}
+		record, err := res.Single(ctx)
+		if err != nil {
+			return 0, err
+		}
+		count, _ := record.Get("merged")
+		c, _ := count.(int64)
+		return int(c), nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return merged.(int), nil
+}
+
+// netBIOSResolver looks up an AD domain's real NetBIOS name over LDAP
+// (CN=Partitions,CN=Configuration,DC=...) instead of assuming it matches the
+// first label of the domain's FQDN, which is wrong whenever the two diverge.
+// Results are cached on disk between runs to avoid re-querying AD.
+type netBIOSResolver struct {
+	user, pass string
+	cachePath  string
+	cache      map[string]string
+	logger     hclog.Logger
+}
+
+func newNetBIOSResolver(cachePath, user, pass string, logger hclog.Logger) *netBIOSResolver {
+	r := &netBIOSResolver{user: user, pass: pass, cachePath: cachePath, cache: make(map[string]string), logger: logger}
+	if data, err := os.ReadFile(cachePath); err == nil {
+		_ = json.Unmarshal(data, &r.cache)
+	}
+	return r
+}
 
-		res, err := neo4j.ExecuteQuery(ctx, driver, cypher, params, neo4j.EagerResultTransformer, neo4j.ExecuteQueryWithDatabase("neo4j"))
+func (r *netBIOSResolver) netBIOS(fqdn string) string {
+	fqdn = strings.ToUpper(fqdn)
+
+	if netbios, ok := r.cache[fqdn]; ok {
+		return netbios
+	}
+
+	netbios, err := r.lookupLDAP(fqdn)
+	if err != nil {
+		r.logger.Warn("LDAP NetBIOS lookup failed, falling back to first-label heuristic", "fqdn", fqdn, "error", err)
+		parts := strings.Split(fqdn, ".")
+		netbios = parts[0]
+	}
+
+	r.cache[fqdn] = netbios
+	if data, err := json.MarshalIndent(r.cache, "", "  "); err == nil {
+		_ = os.WriteFile(r.cachePath, data, 0600)
+	}
+	return netbios
+}
+
+func (r *netBIOSResolver) lookupLDAP(fqdn string) (string, error) {
+	conn, err := ldap.DialURL(fmt.Sprintf("ldaps://%s:636", fqdn), ldap.DialWithTLSConfig(&tls.Config{InsecureSkipVerify: true}))
+	if err != nil {
+		conn, err = ldap.DialURL(fmt.Sprintf("ldap://%s:389", fqdn))
 		if err != nil {
-			log.Printf("Error syncing %s: %v", targetName, err)
-		} else {
-			// Check if it actually matched
-			if len(res.Records) > 0 {
-				c := res.Records[0].Values[0].(int64)
-				if c > 0 {
-					count++
-					fmt.Printf("Linked %s -> %s\n", targetName, node.ElementId)
-				}
-			}
+			return "", fmt.Errorf("failed to connect to LDAP on %s: %w", fqdn, err)
 		}
 	}
-	fmt.Printf("Synced %d %s relationships.\n", count, relType)
+	defer conn.Close()
+
+	if r.user != "" {
+		if err := conn.Bind(r.user, r.pass); err != nil {
+			return "", fmt.Errorf("LDAP bind failed: %w", err)
+		}
+	} else if err := conn.UnauthenticatedBind(""); err != nil {
+		return "", fmt.Errorf("anonymous LDAP bind failed: %w", err)
+	}
+
+	labels := strings.Split(fqdn, ".")
+	dnParts := make([]string, len(labels))
+	for i, l := range labels {
+		dnParts[i] = "DC=" + l
+	}
+	configDN := fmt.Sprintf("CN=Partitions,CN=Configuration,%s", strings.Join(dnParts, ","))
+
+	req := ldap.NewSearchRequest(
+		configDN,
+		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
+		fmt.Sprintf("(&(objectClass=crossRef)(dnsRoot=%s))", ldap.EscapeFilter(fqdn)),
+		[]string{"nETBIOSName"},
+		nil,
+	)
+
+	res, err := conn.Search(req)
+	if err != nil {
+		return "", fmt.Errorf("LDAP search failed: %w", err)
+	}
+
+	for _, entry := range res.Entries {
+		if netbios := entry.GetAttributeValue("nETBIOSName"); netbios != "" {
+			return strings.ToUpper(netbios), nil
+		}
+	}
+
+	return "", fmt.Errorf("no nETBIOSName found under %s", configDN)
 }
@@ -2,22 +2,48 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"math/rand"
 	"net/http"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
+
+	"gopkg.in/yaml.v3"
 )
 
+// defaultModel is the canonical vCenter custom-nodes schema, embedded so the
+// binary can run standalone without a model.json sidecar file. -model, when
+// set, overrides it.
+//
+//go:embed model.json
+var defaultModel []byte
+
 func main() {
 	serverPtr := flag.String("s", "", "BloodHound URL (e.g. http://localhost:8080)")
 	userPtr := flag.String("u", "", "Username")
 	passPtr := flag.String("p", "", "Password")
-	modelPtr := flag.String("model", "model.json", "Path to model.json file")
+	modelPtr := flag.String("model", "", "Path to model.json file (defaults to the embedded vCenter schema)")
+	tokenIDPtr := flag.String("token-id", "", "BloodHound API token ID")
+	tokenKeyPtr := flag.String("token-key", "", "BloodHound API token key")
+	dryRunPtr := flag.Bool("dry-run", false, "Print a diff against the installed schema and exit without uploading")
+	diffPtr := flag.Bool("diff", false, "Print a diff against the installed schema before uploading")
+	forcePtr := flag.Bool("force", false, "Upload even if the diff shows destructive changes (removed kinds)")
+	batchPtr := flag.String("batch", "", "Path to a YAML/JSON config listing multiple BloodHound targets to upload to concurrently")
+	concurrencyPtr := flag.Int("concurrency", 4, "Number of targets to upload to concurrently in -batch mode")
 
 	// Support long flags too
 	flag.StringVar(serverPtr, "server", "", "BloodHound URL")
@@ -26,23 +52,199 @@ func main() {
 
 	flag.Parse()
 
-	if *serverPtr == "" || *userPtr == "" || *passPtr == "" {
+	if *batchPtr != "" {
+		runBatch(*batchPtr, *modelPtr, *concurrencyPtr)
+		return
+	}
+
+	// Env vars let credentials stay off the command line (and out of shell
+	// history); explicit flags still win if both are set.
+	server := firstNonEmpty(*serverPtr, os.Getenv("BLOODHOUND_URL"))
+	tokenID := firstNonEmpty(*tokenIDPtr, os.Getenv("BLOODHOUND_TOKEN_ID"))
+	tokenKey := firstNonEmpty(*tokenKeyPtr, os.Getenv("BLOODHOUND_TOKEN_KEY"))
+	hasToken := tokenID != "" && tokenKey != ""
+	hasLogin := *userPtr != "" && *passPtr != ""
+
+	if server == "" || (!hasToken && !hasLogin) {
 		flag.Usage()
-		fmt.Println("\nExample:\n  vCenterSchemaUploader.exe -s http://localhost:8080 -u admin -p password")
+		fmt.Println("\nExample:")
+		fmt.Println("  vCenterSchemaUploader.exe -s http://localhost:8080 -u admin -p password")
+		fmt.Println("  vCenterSchemaUploader.exe -s http://localhost:8080 -token-id <id> -token-key <key>")
+		fmt.Println("\nCredentials may also be supplied via the BLOODHOUND_URL, BLOODHOUND_TOKEN_ID")
+		fmt.Println("and BLOODHOUND_TOKEN_KEY environment variables.")
 		os.Exit(1)
 	}
 
 	log.Println("Starting Schema Upload...")
-	err := UploadSchema(*serverPtr, *userPtr, *passPtr, *modelPtr)
+	result := Upload(context.Background(), Target{
+		URL:       server,
+		Username:  *userPtr,
+		Password:  *passPtr,
+		TokenID:   tokenID,
+		TokenKey:  tokenKey,
+		ModelPath: *modelPtr,
+		DryRun:    *dryRunPtr,
+		Diff:      *diffPtr,
+		Force:     *forcePtr,
+	})
+	if !result.Success {
+		log.Fatalf("Error: %s", result.Error)
+	}
+	log.Println("Done.")
+}
+
+// runBatch loads a multi-target config from path and uploads to every target
+// concurrently, printing a JSON report and exiting non-zero if any target
+// failed.
+func runBatch(path, defaultModelPath string, concurrency int) {
+	cfg, err := loadBatchConfig(path)
 	if err != nil {
 		log.Fatalf("Error: %v", err)
 	}
-	log.Println("Done.")
+
+	for i := range cfg.Targets {
+		if cfg.Targets[i].ModelPath == "" {
+			cfg.Targets[i].ModelPath = defaultModelPath
+		}
+	}
+
+	results := UploadAll(context.Background(), cfg.Targets, concurrency)
+
+	report, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	fmt.Println(string(report))
+
+	for _, r := range results {
+		if !r.Success {
+			os.Exit(1)
+		}
+	}
 }
 
-// UploadSchema authenticates and uploads the model file to BloodHound
-func UploadSchema(baseURL, username, password, modelPath string) error {
-	// Ensure URL has protocol
+// firstNonEmpty returns the first non-empty value in vals, or "" if all are
+// empty.
+func firstNonEmpty(vals ...string) string {
+	for _, v := range vals {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// Target describes one BloodHound instance to upload a schema to: its URL,
+// the credentials to authenticate with (either username/password or a
+// long-lived API key, same rule as newAuthenticator), the model file to
+// upload, and the diff-mode flags to apply.
+type Target struct {
+	Name      string `json:"name,omitempty" yaml:"name,omitempty"`
+	URL       string `json:"url" yaml:"url"`
+	Username  string `json:"username,omitempty" yaml:"username,omitempty"`
+	Password  string `json:"password,omitempty" yaml:"password,omitempty"`
+	TokenID   string `json:"tokenId,omitempty" yaml:"tokenId,omitempty"`
+	TokenKey  string `json:"tokenKey,omitempty" yaml:"tokenKey,omitempty"`
+	ModelPath string `json:"model,omitempty" yaml:"model,omitempty"`
+	DryRun    bool   `json:"dryRun,omitempty" yaml:"dryRun,omitempty"`
+	Diff      bool   `json:"diff,omitempty" yaml:"diff,omitempty"`
+	Force     bool   `json:"force,omitempty" yaml:"force,omitempty"`
+}
+
+// label identifies target in logs and reports: its Name if set, else its URL.
+func (t Target) label() string {
+	if t.Name != "" {
+		return t.Name
+	}
+	return t.URL
+}
+
+// BatchConfig is the shape of the -batch config file: a list of BloodHound
+// instances to upload a schema to. Targets without their own model path fall
+// back to the CLI's -model flag.
+type BatchConfig struct {
+	Targets []Target `json:"targets" yaml:"targets"`
+}
+
+// loadBatchConfig reads a batch config file. YAML is a superset of JSON, so
+// the same decoder handles either format.
+func loadBatchConfig(path string) (BatchConfig, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return BatchConfig{}, fmt.Errorf("failed to read batch config %s: %v", path, err)
+	}
+
+	var cfg BatchConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return BatchConfig{}, fmt.Errorf("failed to parse batch config %s: %v", path, err)
+	}
+	return cfg, nil
+}
+
+// Result is the outcome of uploading a schema to one Target.
+type Result struct {
+	Target    string `json:"target"`
+	Success   bool   `json:"success"`
+	Error     string `json:"error,omitempty"`
+	LatencyMS int64  `json:"latencyMs"`
+}
+
+// Upload authenticates, optionally diffs, and uploads the schema described
+// by target, returning a Result rather than an error so UploadAll can
+// aggregate outcomes across many targets without one failure stopping the
+// rest. ctx is checked between steps so a caller can cancel an in-progress
+// batch.
+func Upload(ctx context.Context, target Target) Result {
+	start := time.Now()
+	result := Result{Target: target.label()}
+
+	if err := uploadTarget(ctx, target); err != nil {
+		result.Error = err.Error()
+	} else {
+		result.Success = true
+	}
+
+	result.LatencyMS = time.Since(start).Milliseconds()
+	return result
+}
+
+// UploadAll uploads the schema to every target concurrently, bounded by
+// concurrency workers, and returns one Result per target in the same order
+// targets were given.
+func UploadAll(ctx context.Context, targets []Target, concurrency int) []Result {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	results := make([]Result, len(targets))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for i, target := range targets {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, target Target) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = Upload(ctx, target)
+		}(i, target)
+	}
+
+	wg.Wait()
+	return results
+}
+
+// uploadTarget authenticates and uploads the model file described by
+// target. If target.TokenID and target.TokenKey are both set they take
+// priority, authenticating with a long-lived API key; otherwise
+// target.Username/Password are used to open a login session.
+//
+// If target.DryRun or target.Diff is set, the currently installed schema is
+// fetched first and a diff against the model is printed. DryRun returns
+// before uploading; Diff prints the summary and continues unless the diff is
+// destructive, in which case either mode requires Force to proceed.
+func uploadTarget(ctx context.Context, target Target) error {
+	baseURL := target.URL
 	if !strings.HasPrefix(baseURL, "http") {
 		baseURL = "http://" + baseURL
 	}
@@ -52,29 +254,383 @@ func UploadSchema(baseURL, username, password, modelPath string) error {
 		Timeout: 30 * time.Second,
 	}
 
-	// 1. Login
-	log.Printf("Connecting to %s...", baseURL)
-	token, err := login(client, baseURL, username, password)
+	// 1. Authenticate
+	log.Printf("[%s] Connecting to %s...", target.label(), baseURL)
+	auth, err := newAuthenticator(client, baseURL, target.Username, target.Password, target.TokenID, target.TokenKey)
 	if err != nil {
-		return fmt.Errorf("login failed: %v", err)
+		return fmt.Errorf("authentication failed: %v", err)
+	}
+	log.Printf("[%s] Successfully authenticated with BloodHound", target.label())
+
+	if err := ctx.Err(); err != nil {
+		return err
 	}
-	log.Println("Successfully authenticated with BloodHound")
 
-	// 2. Read Model
-	log.Printf("Reading model file: %s", modelPath)
-	modelData, err := ioutil.ReadFile(modelPath)
+	// 2. Read and validate the model
+	log.Printf("[%s] Reading model file: %s", target.label(), modelLabel(target.ModelPath))
+	modelData, err := loadModel(target.ModelPath)
 	if err != nil {
 		return fmt.Errorf("failed to read model file: %v", err)
 	}
+	if err := ValidateModel(modelData); err != nil {
+		return fmt.Errorf("invalid model: %v", err)
+	}
 
-	// 3. Upload
-	log.Println("Uploading custom nodes schema...")
-	err = upload(client, baseURL, token, modelData)
-	if err != nil {
+	// 3. Diff against the installed schema, if asked
+	if target.DryRun || target.Diff {
+		installed, err := FetchSchema(client, auth, baseURL)
+		if err != nil {
+			return fmt.Errorf("failed to fetch installed schema: %v", err)
+		}
+
+		report, err := SchemaDiff(modelData, installed)
+		if err != nil {
+			return fmt.Errorf("failed to diff schema: %v", err)
+		}
+		fmt.Printf("[%s]\n%s\n", target.label(), report.String())
+
+		if report.Destructive() && !target.Force {
+			return fmt.Errorf("local model removes kinds present in the installed schema; re-run with -force to upload anyway")
+		}
+		if target.DryRun {
+			log.Printf("[%s] Dry run complete, not uploading.", target.label())
+			return nil
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// 4. Upload
+	log.Printf("[%s] Uploading custom nodes schema...", target.label())
+	if err := NewChunkedUploader(client, auth).Upload(baseURL, modelData); err != nil {
 		return fmt.Errorf("upload failed: %v", err)
 	}
 
-	log.Println("Model uploaded successfully!")
+	log.Printf("[%s] Model uploaded successfully!", target.label())
+	return nil
+}
+
+// Schema is the shape of BloodHound's custom-nodes schema document: one
+// entry per custom node kind (with the icon BloodHound renders it as), plus
+// the relationship kinds allowed to connect them. Field names follow
+// BloodHound's own custom-nodes API payload.
+type Schema struct {
+	NodeKinds         []NodeKind `json:"node_kinds"`
+	RelationshipKinds []string   `json:"relationship_kinds"`
+}
+
+// NodeKind describes one custom node kind and how BloodHound should render
+// it.
+type NodeKind struct {
+	Name string   `json:"kind_name"`
+	Icon NodeIcon `json:"icon"`
+}
+
+// NodeIcon is BloodHound's icon descriptor, e.g. a Font Awesome glyph name
+// and a display color.
+type NodeIcon struct {
+	Type  string `json:"type"`
+	Name  string `json:"name"`
+	Color string `json:"color"`
+}
+
+// loadModel reads the model to upload from path, falling back to the
+// embedded default vCenter schema when path is empty.
+func loadModel(path string) ([]byte, error) {
+	if path == "" {
+		return defaultModel, nil
+	}
+	return ioutil.ReadFile(path)
+}
+
+// modelLabel describes path for logging, naming the embedded default when
+// path is empty.
+func modelLabel(path string) string {
+	if path == "" {
+		return "<embedded default>"
+	}
+	return path
+}
+
+// ValidateModel parses data as a Schema and checks it has the fields
+// BloodHound's custom-nodes API requires, so a hand-edited model fails with
+// a clear local error instead of an opaque HTTP 400 from BloodHound.
+func ValidateModel(data []byte) error {
+	var schema Schema
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return fmt.Errorf("invalid JSON: %v", err)
+	}
+
+	if len(schema.NodeKinds) == 0 {
+		return fmt.Errorf("model has no node_kinds")
+	}
+
+	seenKinds := make(map[string]bool, len(schema.NodeKinds))
+	for i, kind := range schema.NodeKinds {
+		if kind.Name == "" {
+			return fmt.Errorf("node_kinds[%d]: kind_name is required", i)
+		}
+		if seenKinds[kind.Name] {
+			return fmt.Errorf("node_kinds[%d]: duplicate kind_name %q", i, kind.Name)
+		}
+		seenKinds[kind.Name] = true
+
+		if kind.Icon.Type == "" {
+			return fmt.Errorf("node_kinds[%d] (%s): icon.type is required", i, kind.Name)
+		}
+		if kind.Icon.Name == "" {
+			return fmt.Errorf("node_kinds[%d] (%s): icon.name is required", i, kind.Name)
+		}
+	}
+
+	seenRel := make(map[string]bool, len(schema.RelationshipKinds))
+	for i, kind := range schema.RelationshipKinds {
+		if kind == "" {
+			return fmt.Errorf("relationship_kinds[%d]: must not be empty", i)
+		}
+		if seenRel[kind] {
+			return fmt.Errorf("relationship_kinds[%d]: duplicate %q", i, kind)
+		}
+		seenRel[kind] = true
+	}
+
+	return nil
+}
+
+// DiffReport summarizes the differences between a local model.json and the
+// schema already installed in BloodHound.
+type DiffReport struct {
+	AddedKinds               []string
+	RemovedKinds             []string
+	ChangedIcons             []string
+	AddedRelationshipKinds   []string
+	RemovedRelationshipKinds []string
+}
+
+// HasChanges reports whether the local model differs from the installed
+// schema at all.
+func (r DiffReport) HasChanges() bool {
+	return len(r.AddedKinds) > 0 || len(r.RemovedKinds) > 0 || len(r.ChangedIcons) > 0 ||
+		len(r.AddedRelationshipKinds) > 0 || len(r.RemovedRelationshipKinds) > 0
+}
+
+// Destructive reports whether applying the local model would remove a node
+// kind or relationship kind the installed schema already has, which usually
+// means clobbering a customization made in the UI.
+func (r DiffReport) Destructive() bool {
+	return len(r.RemovedKinds) > 0 || len(r.RemovedRelationshipKinds) > 0
+}
+
+// String renders r as the human-readable summary printed by -dry-run and
+// -diff.
+func (r DiffReport) String() string {
+	if !r.HasChanges() {
+		return "No differences between local model and installed schema."
+	}
+
+	var b strings.Builder
+	writeList := func(label string, items []string) {
+		if len(items) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "%s:\n", label)
+		for _, item := range items {
+			fmt.Fprintf(&b, "  - %s\n", item)
+		}
+	}
+
+	writeList("Added node kinds", r.AddedKinds)
+	writeList("Removed node kinds", r.RemovedKinds)
+	writeList("Changed icons", r.ChangedIcons)
+	writeList("Added relationship kinds", r.AddedRelationshipKinds)
+	writeList("Removed relationship kinds", r.RemovedRelationshipKinds)
+
+	if r.Destructive() {
+		b.WriteString("WARNING: this upload would remove kinds currently installed in BloodHound.\n")
+	}
+
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// SchemaDiff parses local and remote as Schema documents and reports added/
+// removed node kinds, icon changes, and added/removed relationship kinds.
+func SchemaDiff(local, remote []byte) (DiffReport, error) {
+	var localSchema, remoteSchema Schema
+	if err := json.Unmarshal(local, &localSchema); err != nil {
+		return DiffReport{}, fmt.Errorf("failed to parse local model: %v", err)
+	}
+	if err := json.Unmarshal(remote, &remoteSchema); err != nil {
+		return DiffReport{}, fmt.Errorf("failed to parse installed schema: %v", err)
+	}
+
+	localKinds := make(map[string]NodeIcon, len(localSchema.NodeKinds))
+	for _, k := range localSchema.NodeKinds {
+		localKinds[k.Name] = k.Icon
+	}
+	remoteKinds := make(map[string]NodeIcon, len(remoteSchema.NodeKinds))
+	for _, k := range remoteSchema.NodeKinds {
+		remoteKinds[k.Name] = k.Icon
+	}
+
+	var report DiffReport
+	for name, icon := range localKinds {
+		remoteIcon, ok := remoteKinds[name]
+		if !ok {
+			report.AddedKinds = append(report.AddedKinds, name)
+			continue
+		}
+		if icon != remoteIcon {
+			report.ChangedIcons = append(report.ChangedIcons, name)
+		}
+	}
+	for name := range remoteKinds {
+		if _, ok := localKinds[name]; !ok {
+			report.RemovedKinds = append(report.RemovedKinds, name)
+		}
+	}
+
+	localRel := stringSet(localSchema.RelationshipKinds)
+	remoteRel := stringSet(remoteSchema.RelationshipKinds)
+	for name := range localRel {
+		if !remoteRel[name] {
+			report.AddedRelationshipKinds = append(report.AddedRelationshipKinds, name)
+		}
+	}
+	for name := range remoteRel {
+		if !localRel[name] {
+			report.RemovedRelationshipKinds = append(report.RemovedRelationshipKinds, name)
+		}
+	}
+
+	sort.Strings(report.AddedKinds)
+	sort.Strings(report.RemovedKinds)
+	sort.Strings(report.ChangedIcons)
+	sort.Strings(report.AddedRelationshipKinds)
+	sort.Strings(report.RemovedRelationshipKinds)
+
+	return report, nil
+}
+
+func stringSet(vals []string) map[string]bool {
+	set := make(map[string]bool, len(vals))
+	for _, v := range vals {
+		set[v] = true
+	}
+	return set
+}
+
+// FetchSchema GETs the schema currently installed in BloodHound. Based on
+// standard BloodHound API patterns, this is assumed to be the same endpoint
+// custom-nodes are POSTed to, returning the installed document on GET.
+func FetchSchema(client *http.Client, auth Authenticator, baseURL string) ([]byte, error) {
+	req, err := http.NewRequest("GET", baseURL+"/api/v2/custom-nodes", nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := auth.Authorize(req, nil); err != nil {
+		return nil, fmt.Errorf("failed to authorize request: %v", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("status %d: %s", resp.StatusCode, string(body))
+	}
+
+	return body, nil
+}
+
+// Authenticator authorizes an outgoing request against BloodHound.
+// ChunkedUploader and FetchSchema call it on every request they send, so the
+// transport itself doesn't care
+// whether the credential behind it is a session token or a long-lived API
+// key.
+type Authenticator interface {
+	// Authorize sets whatever headers req needs to be accepted by BloodHound.
+	// body is req's payload (nil if it has none) -- API key signing needs to
+	// read it before it's attached to req.
+	Authorize(req *http.Request, body []byte) error
+}
+
+// newAuthenticator picks an Authenticator based on which credentials were
+// supplied, preferring the API key since it needs no login round trip and
+// doesn't expire the way a session token does.
+func newAuthenticator(client *http.Client, baseURL, username, password, tokenID, tokenKey string) (Authenticator, error) {
+	if tokenID != "" && tokenKey != "" {
+		return &APIKeyAuthenticator{TokenID: tokenID, TokenKey: tokenKey}, nil
+	}
+	return newSessionAuthenticator(client, baseURL, username, password)
+}
+
+// SessionAuthenticator authorizes requests with a session token obtained by
+// logging in with a username and password.
+type SessionAuthenticator struct {
+	Token string
+}
+
+// newSessionAuthenticator logs in and returns an Authenticator holding the
+// resulting session token.
+func newSessionAuthenticator(client *http.Client, baseURL, username, password string) (*SessionAuthenticator, error) {
+	token, err := login(client, baseURL, username, password)
+	if err != nil {
+		return nil, fmt.Errorf("login failed: %v", err)
+	}
+	return &SessionAuthenticator{Token: token}, nil
+}
+
+func (a *SessionAuthenticator) Authorize(req *http.Request, body []byte) error {
+	req.Header.Set("Authorization", "Bearer "+a.Token)
+	return nil
+}
+
+// APIKeyAuthenticator authorizes requests with a long-lived BloodHound API
+// key, signing each request per BloodHound CE's HMAC-SHA256 scheme: a
+// chained digest over the request's method+URI, the hour-truncated request
+// time, and the body, keyed progressively off the token secret.
+type APIKeyAuthenticator struct {
+	TokenID  string
+	TokenKey string
+}
+
+func (a *APIKeyAuthenticator) Authorize(req *http.Request, body []byte) error {
+	ts := time.Now().Format(time.RFC3339)
+	if len(ts) < 13 {
+		return fmt.Errorf("timestamp format error")
+	}
+
+	uri := req.URL.Path
+	if req.URL.RawQuery != "" {
+		uri += "?" + req.URL.RawQuery
+	}
+
+	// H1 = HMAC(TokenKey, Method + URI)
+	h1 := hmac.New(sha256.New, []byte(a.TokenKey))
+	h1.Write([]byte(req.Method + uri))
+	d1 := h1.Sum(nil)
+
+	// H2 = HMAC(H1, Timestamp truncated to the hour, e.g. "2020-12-01T23")
+	h2 := hmac.New(sha256.New, d1)
+	h2.Write([]byte(ts[:13]))
+	d2 := h2.Sum(nil)
+
+	// H3 = HMAC(H2, Body)
+	h3 := hmac.New(sha256.New, d2)
+	h3.Write(body)
+	d3 := h3.Sum(nil)
+
+	req.Header.Set("Authorization", fmt.Sprintf("bhesignature %s", a.TokenID))
+	req.Header.Set("RequestDate", ts)
+	req.Header.Set("Signature", base64.StdEncoding.EncodeToString(d3))
 	return nil
 }
 
@@ -117,27 +673,239 @@ func login(client *http.Client, baseURL, username, password string) (string, err
 	return result.Data.SessionToken, nil
 }
 
-func upload(client *http.Client, baseURL, token string, data []byte) error {
-	uploadURL := baseURL + "/api/v2/custom-nodes"
+// Defaults for ChunkedUploader.
+const (
+	defaultUploadChunkSize      = 4 << 20 // 4MB
+	defaultUploadMaxRetries     = 5
+	defaultUploadAttemptTimeout = 30 * time.Second
+)
+
+// ChunkedUploader streams a payload to BloodHound's custom-nodes endpoint in
+// fixed-size chunks instead of one request, so a multi-MB model.json from a
+// large vCenter collection doesn't fail outright on a single request
+// timeout. It follows the same initiate/PATCH-with-Location/finalize shape
+// as Docker's distribution registry blob upload (httpBlobUpload.ReadFrom):
+// each PATCH response's Range header tells the client how much BloodHound
+// has acknowledged, so a transient 5xx or network error only costs the
+// failed chunk -- Upload resumes from the last acknowledged offset rather
+// than restarting the whole payload. Not every BloodHound deployment speaks
+// this protocol on /api/v2/custom-nodes, though, so Upload falls back to a
+// single plain POST (postWhole) when start can't find a session to resume.
+type ChunkedUploader struct {
+	Client *http.Client
+	Auth   Authenticator
+
+	// ChunkSize is the max number of bytes sent per PATCH request. Defaults
+	// to defaultUploadChunkSize if zero.
+	ChunkSize int
+	// MaxRetries bounds how many attempts are made at a single chunk before
+	// Upload gives up. Defaults to defaultUploadMaxRetries if zero.
+	MaxRetries int
+	// AttemptTimeout bounds a single request. Defaults to
+	// defaultUploadAttemptTimeout if zero.
+	AttemptTimeout time.Duration
+}
+
+// NewChunkedUploader builds a ChunkedUploader with the package defaults.
+func NewChunkedUploader(client *http.Client, auth Authenticator) *ChunkedUploader {
+	return &ChunkedUploader{
+		Client:         client,
+		Auth:           auth,
+		ChunkSize:      defaultUploadChunkSize,
+		MaxRetries:     defaultUploadMaxRetries,
+		AttemptTimeout: defaultUploadAttemptTimeout,
+	}
+}
 
-	req, err := http.NewRequest("POST", uploadURL, bytes.NewBuffer(data))
+// errNoResumableSession means the server responded to start's initiate POST
+// without a Location header, so it doesn't implement the chunked upload
+// protocol ChunkedUploader was written against.
+var errNoResumableSession = errors.New("server did not return a Location to upload chunks to")
+
+// Upload splits data into chunks and streams them to baseURL's custom-nodes
+// endpoint, resuming from the offset BloodHound last acknowledged whenever a
+// chunk attempt fails transiently. If the server doesn't support that
+// initiate/PATCH protocol at all, it falls back to postWhole -- a single
+// plain POST of the whole payload, which is what BloodHound's real
+// /api/v2/custom-nodes endpoint accepts.
+func (u *ChunkedUploader) Upload(baseURL string, data []byte) error {
+	chunkSize := u.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = defaultUploadChunkSize
+	}
+	maxRetries := u.MaxRetries
+	if maxRetries <= 0 {
+		maxRetries = defaultUploadMaxRetries
+	}
+
+	location, offset, err := u.start(baseURL, len(data))
+	if errors.Is(err, errNoResumableSession) {
+		log.Printf("server does not support chunked custom-nodes uploads, falling back to a single POST")
+		return u.postWhole(baseURL, data)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to start chunked upload: %v", err)
+	}
+
+	for offset < len(data) {
+		end := offset + chunkSize
+		if end > len(data) {
+			end = len(data)
+		}
+
+		var attemptErr error
+		for attempt := 0; attempt < maxRetries; attempt++ {
+			var newOffset int
+			newOffset, attemptErr = u.sendChunk(location, data[offset:end], offset, len(data))
+			if attemptErr == nil {
+				offset = newOffset
+				break
+			}
+
+			backoff := time.Duration(1<<uint(attempt))*time.Second + time.Duration(rand.Intn(250))*time.Millisecond
+			log.Printf("chunk upload failed at offset %d, retrying (attempt %d/%d): %v", offset, attempt+1, maxRetries, attemptErr)
+			time.Sleep(backoff)
+		}
+		if attemptErr != nil {
+			return fmt.Errorf("giving up on chunk at offset %d after %d attempts: %v", offset, maxRetries, attemptErr)
+		}
+	}
+
+	return u.finish(location)
+}
+
+// start opens a chunked upload session and returns the Location to PATCH
+// chunks to, along with the offset to resume from (0 for a fresh session).
+func (u *ChunkedUploader) start(baseURL string, totalSize int) (string, int, error) {
+	req, err := http.NewRequest("POST", baseURL+"/api/v2/custom-nodes", nil)
+	if err != nil {
+		return "", 0, err
+	}
+	req.Header.Set("Upload-Length", fmt.Sprintf("%d", totalSize))
+
+	resp, err := u.do(req, nil)
+	if err != nil {
+		return "", 0, err
+	}
+	defer resp.Body.Close()
+
+	location := resp.Header.Get("Location")
+	if location == "" {
+		return "", 0, errNoResumableSession
+	}
+	if !strings.HasPrefix(location, "http") {
+		location = baseURL + location
+	}
+
+	offset, _ := parseRangeEnd(resp.Header.Get("Range"))
+	return location, offset, nil
+}
+
+// sendChunk PATCHes a single chunk starting at offset and returns the offset
+// BloodHound acknowledged, read back from the response's Range header (or
+// offset+len(chunk) if the response didn't include one).
+func (u *ChunkedUploader) sendChunk(location string, chunk []byte, offset, total int) (int, error) {
+	req, err := http.NewRequest("PATCH", location, bytes.NewReader(chunk))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, offset+len(chunk)-1, total))
+
+	resp, err := u.do(req, chunk)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if newOffset, ok := parseRangeEnd(resp.Header.Get("Range")); ok {
+		return newOffset, nil
+	}
+	return offset + len(chunk), nil
+}
+
+// finish marks the upload session at location complete.
+func (u *ChunkedUploader) finish(location string) error {
+	req, err := http.NewRequest("PUT", location, nil)
 	if err != nil {
 		return err
 	}
+	resp, err := u.do(req, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return nil
+}
 
+// postWhole sends the entire payload as a single POST to the custom-nodes
+// endpoint, for servers that don't support the chunked initiate/PATCH
+// protocol start looks for.
+func (u *ChunkedUploader) postWhole(baseURL string, data []byte) error {
+	req, err := http.NewRequest("POST", baseURL+"/api/v2/custom-nodes", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+token)
 
-	resp, err := client.Do(req)
+	resp, err := u.do(req, data)
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to post schema: %v", err)
 	}
 	defer resp.Body.Close()
+	return nil
+}
+
+// do authorizes and sends req with AttemptTimeout applied, returning an
+// error for non-2xx responses as well as network failures so callers can
+// treat both uniformly for retry purposes.
+func (u *ChunkedUploader) do(req *http.Request, body []byte) (*http.Response, error) {
+	if err := u.Auth.Authorize(req, body); err != nil {
+		return nil, err
+	}
+
+	timeout := u.AttemptTimeout
+	if timeout <= 0 {
+		timeout = defaultUploadAttemptTimeout
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
+	defer cancel()
+	req = req.WithContext(ctx)
+
+	resp, err := u.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		body, _ := ioutil.ReadAll(resp.Body)
-		return fmt.Errorf("status %d: %s", resp.StatusCode, string(body))
+		respBody, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, fmt.Errorf("status %d: %s", resp.StatusCode, string(respBody))
 	}
 
-	return nil
+	return resp, nil
+}
+
+// parseRangeEnd extracts the exclusive end offset from a "Range" or
+// "Content-Range" style header such as "bytes=0-4194303" or
+// "bytes 0-4194303/8388608", returning ok=false if the header is empty or
+// malformed.
+func parseRangeEnd(header string) (int, bool) {
+	if header == "" {
+		return 0, false
+	}
+	header = strings.TrimPrefix(header, "bytes=")
+	header = strings.TrimPrefix(header, "bytes ")
+	if i := strings.Index(header, "/"); i >= 0 {
+		header = header[:i]
+	}
+	parts := strings.SplitN(header, "-", 2)
+	if len(parts) != 2 {
+		return 0, false
+	}
+	end, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, false
+	}
+	return end + 1, true
 }